@@ -0,0 +1,107 @@
+package auction_butler
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+func (bot *Bot) isBotOnlyAdmin(id int) bool {
+	for _, admin := range bot.config.Admins.BotOnly {
+		if admin == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Makes the owner and admin roles in the database match the group's creator
+// and administrators, and the bot-only admins from the config. Roles given
+// with /role or /promote are left alone. Every change goes to the audit log.
+func (bot *Bot) syncAdmins() error {
+	members, err := bot.telegram.GetChatAdministrators(tgbotapi.ChatConfig{ChatID: bot.config.ChatID})
+	if err != nil {
+		return fmt.Errorf("failed to get chat administrators: %v", err)
+	}
+
+	admins := make(map[int]bool)
+	for _, member := range members {
+		if member.User == nil || member.User.IsBot {
+			continue
+		}
+		admins[member.User.ID] = true
+
+		user := bot.db.GetUser(member.User.ID)
+		if user == nil {
+			user = &User{
+				ID:        member.User.ID,
+				UserName:  member.User.UserName,
+				FirstName: member.User.FirstName,
+				LastName:  member.User.LastName,
+				Enlisted:  true,
+			}
+		}
+		role, reason := roleAdmin, "group administrator"
+		if member.IsCreator() {
+			role, reason = roleOwner, "group creator"
+		}
+		if err := bot.syncRole(user, role, reason); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range bot.config.Admins.BotOnly {
+		if admins[id] {
+			continue
+		}
+		admins[id] = true
+		user := bot.db.GetUser(id)
+		if user == nil {
+			log.Printf("bot-only admin %d is not tracked yet", id)
+			continue
+		}
+		if err := bot.syncRole(user, roleAdmin, "bot-only admin"); err != nil {
+			return err
+		}
+	}
+
+	current, err := bot.db.GetAdmins()
+	if err != nil {
+		return fmt.Errorf("failed to get admins: %v", err)
+	}
+	for _, admin := range current {
+		if admins[admin.ID] {
+			continue
+		}
+		if user := bot.db.GetUser(admin.ID); user != nil && user.RoleSynced {
+			if err := bot.syncRole(user, roleBidder, "not a group administrator"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Gives the role to the user, unless they have it or got a role by hand.
+func (bot *Bot) syncRole(user *User, role, reason string) error {
+	if user.Exists() && (user.role() == role || user.RoleManual) {
+		return nil
+	}
+	return bot.changeRole(nil, user, role, reason)
+}
+
+func (bot *Bot) watchAdmins() {
+	interval := bot.config.Admins.SyncInterval.Duration
+	if interval <= 0 {
+		return
+	}
+
+	for {
+		if err := bot.syncAdmins(); err != nil {
+			log.Printf("failed to sync admins: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
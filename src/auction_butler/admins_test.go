@@ -0,0 +1,47 @@
+package auction_butler
+
+import (
+	"testing"
+
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+// The sync gives and takes the roles of the group's administrators, but
+// leaves roles given by hand alone.
+func TestSyncAdmins(t *testing.T) {
+	bot, _, telegram := newTestBot(t, testConfig())
+	creator := tgbotapi.User{ID: 1, UserName: "creator"}
+	moderator := tgbotapi.User{ID: 4, UserName: "moderator"}
+	demoted := tgbotapi.User{ID: 5, UserName: "demoted"}
+	telegram.admins = []tgbotapi.ChatMember{
+		{User: &creator, Status: "creator"},
+		{User: &moderator, Status: "administrator"},
+		{User: &demoted, Status: "administrator"},
+	}
+
+	owner := &User{Role: roleOwner}
+	promoted := &User{ID: 2, UserName: "promoted"}
+	if err := bot.changeRole(owner, promoted, roleAdmin, ""); err != nil {
+		t.Fatal(err)
+	}
+	// group administrators who got another role by hand keep it
+	if err := bot.changeRole(owner, &User{ID: 4, UserName: "moderator"}, roleModerator, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.changeRole(owner, &User{ID: 5, UserName: "demoted"}, roleBidder, ""); err != nil {
+		t.Fatal(err)
+	}
+	former := &User{ID: 3, UserName: "former"}
+	if err := bot.syncRole(former, roleAdmin, "group administrator"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bot.syncAdmins(); err != nil {
+		t.Fatalf("failed to sync admins: %v", err)
+	}
+	for id, want := range map[int]string{1: roleOwner, 2: roleAdmin, 3: roleBidder, 4: roleModerator, 5: roleBidder} {
+		if user := bot.db.GetUser(id); user == nil || user.role() != want {
+			t.Errorf("user %d: got %+v, want %s", id, user, want)
+		}
+	}
+}
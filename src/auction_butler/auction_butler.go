@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"strings"
+	"sync"
 	"time"
 
 	"errors"
@@ -17,10 +18,10 @@ var (
 
 type Bot struct {
 	config                 *Config
-	db                     *DB
+	db                     Store
 	telegram               *tgbotapi.BotAPI
-	commandHandlers        map[string]CommandHandler
-	adminCommandHandlers   map[string]CommandHandler
+	commandHandlers        map[string]Command
+	callbackHandlers       map[string]CallbackHandler
 	privateMessageHandlers []MessageHandler
 	groupMessageHandlers   []MessageHandler
 	rescheduleChan         chan int
@@ -28,6 +29,40 @@ type Bot struct {
 	auctionEndTime         time.Time
 	runningCountDown       bool
 	bidChan                chan int
+	jobsChan               chan int
+	paymentWatchers        map[string]PaymentWatcher
+	clock                  Clock
+	groupZone              *time.Location
+	// held while creating or deleting the auctions of recurring auctions
+	recurringLock sync.Mutex
+	// guards lastBidMessage and runningCountDown, which the goroutine that
+	// handles updates and the one that runs the auctions share
+	stateLock sync.Mutex
+	// deleted messages per user since they were last muted
+	chatViolations map[int]int
+	messages       Catalog
+}
+
+// Replaces the message of the latest bid, which the winner gets a reply to,
+// and returns the one it replaced.
+func (bot *Bot) swapLastBidMessage(ctx *Context) *Context {
+	bot.stateLock.Lock()
+	defer bot.stateLock.Unlock()
+	last := bot.lastBidMessage
+	bot.lastBidMessage = ctx
+	return last
+}
+
+func (bot *Bot) countdownRunning() bool {
+	bot.stateLock.Lock()
+	defer bot.stateLock.Unlock()
+	return bot.runningCountDown
+}
+
+func (bot *Bot) setCountdownRunning(running bool) {
+	bot.stateLock.Lock()
+	defer bot.stateLock.Unlock()
+	bot.runningCountDown = running
 }
 
 type Context struct {
@@ -43,6 +78,7 @@ type Bid struct {
 }
 
 type CommandHandler func(*Bot, *Context, string, string) error
+type CallbackHandler func(*Bot, *Context, *tgbotapi.CallbackQuery, string) error
 type MessageHandler func(*Bot, *Context, string) (bool, error)
 
 func (b *Bid) String() string {
@@ -64,6 +100,7 @@ func (bot *Bot) enableUser(u *User) ([]string, error) {
 	}
 	if u.Banned {
 		u.Banned = false
+		u.BannedByStrikes = false
 		actions = append(actions, "unbanned")
 	}
 	if !u.Enlisted {
@@ -78,26 +115,51 @@ func (bot *Bot) enableUser(u *User) ([]string, error) {
 	return actions, nil
 }
 
+// Copies changed names from telegram into the stored user. Only writes to the
+// database if something actually changed, and keeps replaced usernames in the
+// history.
+func (bot *Bot) updateProfile(u *User, from *tgbotapi.User) {
+	if u.UserName == from.UserName && u.FirstName == from.FirstName && u.LastName == from.LastName {
+		return
+	}
+
+	if u.UserName != from.UserName && u.UserName != "" {
+		if err := bot.db.PutUsernameChange(u.ID, u.UserName); err != nil {
+			log.Printf("failed to record username change of %s: %v", u.NameAndTags(), err)
+		}
+	}
+	u.UserName = from.UserName
+	u.FirstName = from.FirstName
+	u.LastName = from.LastName
+	if err := bot.db.PutUser(u); err != nil {
+		log.Printf("failed to update profile of %s: %v", u.NameAndTags(), err)
+		return
+	}
+
+	log.Printf("profile updated: %s", u.NameAndTags())
+}
+
 func (bot *Bot) enableUserVerbosely(ctx *Context, dbuser *User) error {
 	actions, err := bot.enableUser(dbuser)
 	if err != nil {
 		return fmt.Errorf("failed to enable user: %v", err)
 	}
 	if len(actions) > 0 {
-		return bot.Reply(ctx, strings.Join(actions, ", "))
+		bot.audit(ctx.User, dbuser.ID, auditEnlist, strings.Join(actions, ", "), "")
+		return bot.ReplyTemplate(ctx, "user_enabled", enabledMessage{actions})
 	}
-	return bot.Reply(ctx, "no action required")
+	return bot.ReplyTemplate(ctx, "no_action", nil)
 }
 
 func (bot *Bot) handleForwardedMessageFrom(ctx *Context, id int) error {
-	args := tgbotapi.ChatConfigWithUser{bot.config.ChatID, "", id}
+	args := tgbotapi.ChatConfigWithUser{ChatID: bot.config.ChatID, UserID: id}
 	member, err := bot.telegram.GetChatMember(args)
 	if err != nil {
 		return fmt.Errorf("failed to get chat member from telegram: %v", err)
 	}
 
 	if !member.IsMember() && !member.IsCreator() && !member.IsAdministrator() {
-		return bot.Reply(ctx, "that user is not a member of the chat")
+		return bot.ReplyTemplate(ctx, "not_member", nil)
 	}
 
 	user := member.User
@@ -116,26 +178,20 @@ func (bot *Bot) handleForwardedMessageFrom(ctx *Context, id int) error {
 }
 
 func (bot *Bot) handleCommand(ctx *Context, command, args string) error {
-	if !ctx.User.Banned {
-		handler, found := bot.commandHandlers[command]
-		if found {
-			return handler(bot, ctx, command, args)
-		}
+	handler, found := bot.commandHandlers[command]
+	if !found {
+		return fmt.Errorf("command not found: %s", command)
 	}
-
-	if ctx.User.Admin {
-		handler, found := bot.adminCommandHandlers[command]
-		if found {
-			return handler(bot, ctx, command, args)
-		}
+	if !ctx.User.Can(handler.Permission) {
+		return fmt.Errorf("you are not allowed to use /%s", command)
 	}
 
-	return fmt.Errorf("command not found: %s", command)
+	return handler.Handlerfunc(bot, ctx, command, args)
 }
 
 func (bot *Bot) handlePrivateMessage(ctx *Context) error {
-	if ctx.User.Admin {
-		// let admin force add users by forwarding their messages
+	if ctx.User.Can(permModerate) {
+		// let moderators force add users by forwarding their messages
 		if u := ctx.message.ForwardFrom; u != nil {
 			if err := bot.handleForwardedMessageFrom(ctx, u.ID); err != nil {
 				return fmt.Errorf("failed to add user %s: %v", u.String(), err)
@@ -149,7 +205,7 @@ func (bot *Bot) handlePrivateMessage(ctx *Context) error {
 		err := bot.handleCommand(ctx, cmd, args)
 		if err != nil {
 			log.Printf("command '/%s %s' failed: %v", cmd, args, err)
-			return bot.Reply(ctx, fmt.Sprintf("command failed: %v", err))
+			return bot.ReplyTemplate(ctx, "command_failed", errorMessage{err.Error()})
 		}
 		return nil
 	}
@@ -174,6 +230,9 @@ func (bot *Bot) handleUserJoin(ctx *Context, user *tgbotapi.User) error {
 		return nil
 	}
 	dbuser := bot.db.GetUser(user.ID)
+	// members who never solved the captcha have to, also when they failed or
+	// left it before, unless a moderator added them
+	challenge := (dbuser == nil || !dbuser.Enlisted) && bot.captchaEnabled() && !(ctx.User != nil && ctx.User.Can(permModerate) && ctx.User.ID != user.ID)
 	if dbuser == nil {
 		dbuser = &User{
 			ID:        user.ID,
@@ -182,28 +241,33 @@ func (bot *Bot) handleUserJoin(ctx *Context, user *tgbotapi.User) error {
 			LastName:  user.LastName,
 		}
 	}
-	dbuser.Enlisted = true
+	dbuser.join(bot.clock.Now())
+	// stays unenlisted until the captcha is solved
+	dbuser.Enlisted = !challenge
 	if err := bot.db.PutUser(dbuser); err != nil {
 		log.Printf("failed to save the user")
 		return err
 	}
+	bot.logMembership(user.ID, memberJoin, ctx.message.From.ID)
 
 	log.Printf("user joined: %s", dbuser.NameAndTags())
-	msg, err := bot.Send(ctx, "reply", "html", `Welcome to the KittyCash Auction group. Please familiarise yourself with the rules in the <a href="t.me/KittyCashAuction/746">pinned message</a> before bidding on a Legendary Kitty.`)
+	if challenge {
+		return bot.challengeUser(dbuser)
+	}
+	return bot.welcome(ctx, "reply")
+}
+
+func (bot *Bot) welcome(ctx *Context, mode string) error {
+	msg, err := bot.SendTemplate(ctx, mode, "welcome", nil)
 
 	if err != nil {
 		return err
 	}
 
-	go func() {
-		time.Sleep(bot.config.MsgDeleteCounter.Duration)
-		bot.telegram.DeleteMessage(tgbotapi.DeleteMessageConfig{
-			ChatID:    bot.config.ChatID,
-			MessageID: msg.MessageID,
-		})
-	}()
-
-	return nil
+	return bot.enqueue(jobDeleteMessage, deleteMessageJob{
+		ChatID:    bot.config.ChatID,
+		MessageID: msg.MessageID,
+	}, bot.clock.Now().Add(bot.config.MsgDeleteCounter.Duration))
 }
 
 func (bot *Bot) handleUserLeft(ctx *Context, user *tgbotapi.User) error {
@@ -211,6 +275,18 @@ func (bot *Bot) handleUserLeft(ctx *Context, user *tgbotapi.User) error {
 		log.Printf("i have left the group")
 		return nil
 	}
+	if captcha := bot.db.GetCaptcha(user.ID); captcha != nil {
+		bot.db.DeleteCaptcha(user.ID)
+		bot.DeleteMsg(bot.config.ChatID, captcha.MessageID)
+	}
+	// kicks by the bot itself are recorded where they happen
+	if from := ctx.message.From; from != nil && from.ID != bot.telegram.Self.ID {
+		event := memberLeave
+		if from.ID != user.ID {
+			event = memberKick
+		}
+		bot.logMembership(user.ID, event, from.ID)
+	}
 	dbuser := bot.db.GetUser(user.ID)
 	if dbuser != nil {
 		dbuser.Enlisted = false
@@ -226,11 +302,18 @@ func (bot *Bot) handleUserLeft(ctx *Context, user *tgbotapi.User) error {
 
 func (bot *Bot) DeleteMsg(chatID int64, msgID int) {
 	bot.telegram.DeleteMessage(tgbotapi.DeleteMessageConfig{
-		bot.config.ChatID,
-		msgID,
+		ChatID:    bot.config.ChatID,
+		MessageID: msgID,
 	})
 }
 
+// Deletes the message of the context from the group and keeps its text in
+// the audit log.
+func (bot *Bot) deleteUserMessage(ctx *Context, reason string) {
+	bot.DeleteMsg(bot.config.ChatID, ctx.message.MessageID)
+	bot.audit(nil, ctx.message.From.ID, auditDelete, reason, ctx.message.Text)
+}
+
 func (bot *Bot) removeMyName(text string) (string, bool) {
 	var removed bool
 	var words []string
@@ -270,30 +353,51 @@ func (bot *Bot) handleGroupMessage(ctx *Context) error {
 		}
 	}
 
+	// staff may moderate from the group, e.g. by replying /ban to a message
+	if ctx.User != nil && ctx.User.IsStaff() && ctx.message.IsCommand() {
+		cmd, args := ctx.message.Command(), ctx.message.CommandArguments()
+		if err := bot.handleCommand(ctx, cmd, args); err != nil {
+			log.Printf("command '/%s %s' failed: %v", cmd, args, err)
+			return bot.ReplyTemplate(ctx, "command_failed", errorMessage{err.Error()})
+		}
+		return gerr
+	}
+
 	if ctx.User != nil {
 		bid, err := findBid(ctx.message.Text)
 
 		//TODO (therealssj): return msgs based on the err returned
 		if err != nil {
-			if err == ErrNoBidFound && !ctx.User.Admin {
-				bot.DeleteMsg(bot.config.ChatID, ctx.message.MessageID)
+			if err == ErrNoBidFound && !ctx.User.IsStaff() && !bot.chatterAllowed(ctx) {
+				bot.deleteUserMessage(ctx, "not a bid")
+				bot.chatViolation(ctx)
 			}
 			return err
 		}
 
+		if reason, text := bot.biddingRestriction(ctx.User); reason != "" {
+			bot.deleteUserMessage(ctx, reason)
+			bot.Whisper(ctx.User.ID, "html", text)
+			return fmt.Errorf("bid from %s rejected: %s", ctx.User.NameAndTags(), reason)
+		}
+
 		auction := bot.db.GetCurrentAuction()
-		if bot.runningCountDown {
-			if auction == nil {
-				if !ctx.User.Admin {
-					bot.DeleteMsg(bot.config.ChatID, ctx.message.MessageID)
-				}
-				return errors.New("No ongoing auction")
+		if auction == nil {
+			if !ctx.User.IsStaff() {
+				bot.deleteUserMessage(ctx, "no ongoing auction")
+			}
+			return errors.New("No ongoing auction")
+		}
+		if auction.Phase == auctionPaused {
+			if !ctx.User.IsStaff() {
+				bot.deleteUserMessage(ctx, "auction paused")
 			}
+			return errors.New("auction is paused")
 		}
 		if bid.CoinType == auction.BidType {
 			if bid.Value <= auction.BidVal {
-				if !ctx.User.Admin {
-					bot.DeleteMsg(bot.config.ChatID, ctx.message.MessageID)
+				if !ctx.User.IsStaff() {
+					bot.deleteUserMessage(ctx, "bid not more than last bid")
 				}
 				return fmt.Errorf("bid not more than last bid of %v", auction.BidVal)
 			}
@@ -301,38 +405,43 @@ func (bot *Bot) handleGroupMessage(ctx *Context) error {
 			switch bid.CoinType {
 			case "BTC":
 				if bid.Value*float64(bot.config.ConversionFactor) <= auction.BidVal {
-					if !ctx.User.Admin {
-						bot.DeleteMsg(bot.config.ChatID, ctx.message.MessageID)
+					if !ctx.User.IsStaff() {
+						bot.deleteUserMessage(ctx, "bid less than last bid")
 					}
 					return errors.New("bid less than last bid")
 
 				}
 			case "SKY":
 				if bid.Value/float64(bot.config.ConversionFactor) <= auction.BidVal {
-					if !ctx.User.Admin {
-						bot.DeleteMsg(bot.config.ChatID, ctx.message.MessageID)
+					if !ctx.User.IsStaff() {
+						bot.deleteUserMessage(ctx, "bid less than last bid")
 					}
 					return errors.New("bid less than last bid")
 				}
 			}
 		}
-		bot.db.SetAuctionBid(auction.ID, bid)
-		bot.bidChan <- 1
+		bot.db.SetAuctionBid(auction.ID, bid, ctx.User.ID)
+		if err := bot.db.PutBid(auction.ID, ctx.User.ID, bid); err != nil {
+			log.Printf("failed to record bid: %v", err)
+		}
+		bot.bidChan <- auction.ID
 
 		//TODO (therealssj): add something to retry sending?
-		msg, _ := bot.Send(ctx, "yell", "html", fmt.Sprintf(`<b>Current bid of %v/%v</b>
-
-Bids only please.`, bid.String(), bid.Convert(bot.config.ConversionFactor)))
+		msg, _ := bot.SendTemplate(ctx, "yell", "current_bid", bidMessage{
+			Bid:       bid.String(),
+			Converted: bid.Convert(bot.config.ConversionFactor),
+		})
 
-		if bot.lastBidMessage != nil {
-			bot.DeleteMsg(bot.config.ChatID, bot.lastBidMessage.message.MessageID)
+		var next *Context
+		if msg != nil && msg.MessageID != 0 {
+			if err := bot.db.SetAuctionMessage(auction.ID, msg.MessageID); err != nil {
+				log.Printf("failed to save bid message: %v", err)
+			}
+			next = &Context{message: msg, User: ctx.User}
 		}
-
-		bot.lastBidMessage = &Context{
-			message: msg,
-			User:    ctx.User,
+		if last := bot.swapLastBidMessage(next); last != nil {
+			bot.DeleteMsg(bot.config.ChatID, last.message.MessageID)
 		}
-
 	}
 
 	return gerr
@@ -366,6 +475,28 @@ func (bot *Bot) Send(ctx *Context, mode, format, text string) (*tgbotapi.Message
 	return &sentMsg, err
 }
 
+// Sends a private message to the user with the given id. Telegram only
+// delivers it if the user has started a conversation with the bot.
+func (bot *Bot) Whisper(userID int, format, text string) (*tgbotapi.Message, error) {
+	ctx := &Context{message: &tgbotapi.Message{From: &tgbotapi.User{ID: userID}}}
+	return bot.Send(ctx, "whisper", format, text)
+}
+
+// Sends the message to every admin, in their language.
+func (bot *Bot) notifyAdmins(name string, data interface{}) {
+	admins, err := bot.db.GetAdmins()
+	if err != nil {
+		log.Printf("failed to get admins: %v", err)
+		return
+	}
+	for i := range admins {
+		admin := &admins[i]
+		if _, err := bot.Whisper(admin.ID, "html", bot.text(admin, name, data)); err != nil {
+			log.Printf("failed to notify admin %s: %v", admin.NameAndTags(), err)
+		}
+	}
+}
+
 func (bot *Bot) Ask(ctx *Context, text string) error {
 	msg := tgbotapi.NewMessage(ctx.message.Chat.ID, text)
 	msg.ReplyMarkup = tgbotapi.ForceReply{
@@ -377,11 +508,6 @@ func (bot *Bot) Ask(ctx *Context, text string) error {
 	return err
 }
 
-func (bot *Bot) Reply(ctx *Context, text string) error {
-	_, err := bot.Send(ctx, "reply", "text", text)
-	return err
-}
-
 func (bot *Bot) handleMessage(ctx *Context) error {
 	if (ctx.message.Chat.IsGroup() || ctx.message.Chat.IsSuperGroup()) && ctx.message.Chat.ID == bot.config.ChatID {
 		return bot.handleGroupMessage(ctx)
@@ -395,12 +521,30 @@ func (bot *Bot) handleMessage(ctx *Context) error {
 
 func NewBot(config Config) (*Bot, error) {
 	var bot = Bot{
-		config:               &config,
-		commandHandlers:      make(map[string]CommandHandler),
-		adminCommandHandlers: make(map[string]CommandHandler),
+		config:           &config,
+		commandHandlers:  make(map[string]Command),
+		callbackHandlers: make(map[string]CallbackHandler),
+		chatViolations:   make(map[int]int),
+		rescheduleChan:   make(chan int),
+		bidChan:          make(chan int, 200),
+		jobsChan:         make(chan int, 1),
+		paymentWatchers:  newPaymentWatchers(&config.Payment),
+		clock:            realClock{},
 	}
 	var err error
 
+	if config.Language == "" {
+		config.Language = "en"
+	}
+	if bot.messages, err = LoadCatalog(&config); err != nil {
+		return nil, err
+	}
+	if config.Timezone != "" {
+		if bot.groupZone, err = loadZone(config.Timezone); err != nil {
+			return nil, fmt.Errorf("invalid group time zone: %v", err)
+		}
+	}
+
 	if bot.db, err = NewDB(&config.Database); err != nil {
 		return nil, fmt.Errorf("failed to open database: %v", err)
 	}
@@ -411,7 +555,7 @@ func NewBot(config Config) (*Bot, error) {
 
 	bot.telegram.Debug = config.Debug
 
-	chat, err := bot.telegram.GetChat(tgbotapi.ChatConfig{config.ChatID, ""})
+	chat, err := bot.telegram.GetChat(tgbotapi.ChatConfig{ChatID: config.ChatID})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get chat info from telegram: %v", err)
 	}
@@ -427,12 +571,67 @@ func NewBot(config Config) (*Bot, error) {
 	return &bot, nil
 }
 
+// Dispatches inline keyboard button presses. The callback data is the name of
+// the handler followed by a colon and its arguments.
+func (bot *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) error {
+	if query.Message == nil || query.From == nil {
+		return nil
+	}
+
+	ctx := Context{message: query.Message, User: bot.db.GetUser(query.From.ID)}
+	if ctx.User == nil || ctx.User.Banned {
+		_, err := bot.telegram.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, bot.text(nil, "join_group", nil)))
+		return err
+	}
+	bot.updateProfile(ctx.User, query.From)
+	bot.adoptLanguage(ctx.User, query.From.LanguageCode)
+
+	name, args := query.Data, ""
+	if i := strings.Index(query.Data, ":"); i >= 0 {
+		name, args = query.Data[:i], query.Data[i+1:]
+	}
+
+	var herr error
+	if handler, found := bot.callbackHandlers[name]; found {
+		herr = handler(bot, &ctx, query, args)
+	} else {
+		herr = fmt.Errorf("callback not found: %s", name)
+	}
+
+	answer := tgbotapi.NewCallback(query.ID, "")
+	if herr != nil {
+		answer.Text = bot.plainText(ctx.User, "callback_failed", errorMessage{herr.Error()})
+	}
+	if _, err := bot.telegram.AnswerCallbackQuery(answer); err != nil {
+		log.Printf("failed to answer callback query: %v", err)
+	}
+	return herr
+}
+
+// Tells whether the message is about its sender joining the group.
+func joinsItself(message *tgbotapi.Message) bool {
+	if message.From == nil || message.NewChatMembers == nil {
+		return false
+	}
+	for _, user := range *message.NewChatMembers {
+		if user.ID == message.From.ID {
+			return true
+		}
+	}
+	return false
+}
+
 func (bot *Bot) handleUpdate(update *tgbotapi.Update) error {
+	if update.CallbackQuery != nil {
+		return bot.handleCallbackQuery(update.CallbackQuery)
+	}
 	if update.Message == nil {
 		return nil
 	}
 	ctx := Context{message: update.Message}
-	if u := ctx.message.From; u != nil {
+	// members joining by themselves are saved by handleUserJoin, which has to
+	// see them as unknown to challenge them
+	if u := ctx.message.From; u != nil && !joinsItself(ctx.message) {
 		dbuser := bot.db.GetUser(u.ID)
 		if dbuser == nil {
 			member, err := bot.telegram.GetChatMember(tgbotapi.ChatConfigWithUser{
@@ -442,23 +641,32 @@ func (bot *Bot) handleUpdate(update *tgbotapi.Update) error {
 			if err != nil {
 				return fmt.Errorf("unable to fetch chat member %v", u.UserName)
 			}
-			admin := member.IsAdministrator() || member.IsCreator()
+			role := roleBidder
+			if member.IsCreator() {
+				role = roleOwner
+			} else if member.IsAdministrator() || bot.isBotOnlyAdmin(u.ID) {
+				role = roleAdmin
+			}
 			log.Printf("message from untracked user: %s", u.String())
 			if (ctx.message.Chat.IsGroup() || ctx.message.Chat.IsSuperGroup()) && ctx.message.Chat.ID == bot.config.ChatID {
 				dbuser = &User{
-					ID:        u.ID,
-					UserName:  u.UserName,
-					FirstName: u.FirstName,
-					LastName:  u.LastName,
-					Admin:     admin,
+					ID:         u.ID,
+					UserName:   u.UserName,
+					FirstName:  u.FirstName,
+					LastName:   u.LastName,
+					Enlisted:   true,
+					Role:       role,
+					RoleSynced: role != roleBidder,
 				}
 				if err := bot.db.PutUser(dbuser); err != nil {
 					return fmt.Errorf("failed to save the user: %v", err)
 				}
 			} else {
-				return bot.Reply(&ctx, "Please join the kittycash auction group.")
+				return bot.ReplyTemplate(&ctx, "join_group", nil)
 			}
 		}
+		bot.updateProfile(dbuser, u)
+		bot.adoptLanguage(dbuser, u.LanguageCode)
 		ctx.User = dbuser
 	}
 
@@ -469,16 +677,9 @@ func (bot *Bot) Start() error {
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 10
 
-	//curAuction := bot.db.GetCurrentAuction()
-	//
-	//if curAuction != nil && curAuction.MessageID != 0 {
-	//	bot.lastBidMessage = &Context{
-	//		message: &tgbotapi.Message{
-	//			MessageID: curAuction.MessageID,
-	//		},
-	//		User: &User{},
-	//	}
-	//}
+	if err := bot.recoverAuctions(); err != nil {
+		return fmt.Errorf("failed to recover auctions: %v", err)
+	}
 
 	updates, err := bot.telegram.GetUpdatesChan(u)
 	if err != nil {
@@ -490,6 +691,16 @@ func (bot *Bot) Start() error {
 	}
 
 	go bot.maintain()
+	go bot.watchPayments()
+	go bot.watchCaptchas()
+	go bot.watchAdmins()
+	go bot.watchRecurring()
+	go bot.watchJobs()
+	go func() {
+		if err := bot.reconcileMembers(); err != nil {
+			log.Printf("failed to check memberships: %v", err)
+		}
+	}()
 	for update := range updates {
 		if err := bot.handleUpdate(&update); err != nil {
 			log.Printf("error: %v", err)
@@ -0,0 +1,81 @@
+package auction_butler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Moderation actions recorded in the audit log
+const (
+	auditDelete  = "delete"
+	auditBan     = "ban"
+	auditUnban   = "unban"
+	auditPromote = "promote"
+	auditDemote  = "demote"
+	auditEnlist  = "enlist"
+	auditStrike  = "strike"
+	auditPardon  = "pardon"
+	auditMute    = "mute"
+)
+
+const auditLogSize = 20
+
+// Appends a moderation action to the audit log. A nil actor means the bot
+// did it on its own. Failures are only logged, moderation goes on anyway.
+func (bot *Bot) audit(actor *User, targetID int, action, reason, message string) {
+	entry := AuditEntry{
+		ActorID:  bot.telegram.Self.ID,
+		TargetID: targetID,
+		Action:   action,
+		Reason:   reason,
+		Message:  message,
+	}
+	if actor != nil {
+		entry.ActorID = actor.ID
+	}
+
+	if err := bot.db.PutAuditEntry(&entry); err != nil {
+		log.Printf("failed to write audit log: %v", err)
+	}
+}
+
+func (bot *Bot) userName(id int) string {
+	if id == bot.telegram.Self.ID {
+		return "bot"
+	}
+	if user := bot.db.GetUser(id); user != nil {
+		return user.Name()
+	}
+	return strconv.Itoa(id)
+}
+
+func (bot *Bot) handleCommandAudit(ctx *Context, command, args string) error {
+	var targetID int
+	if strings.TrimSpace(args) != "" || ctx.message.ReplyToMessage != nil {
+		user, _, err := bot.targetUser(ctx, args)
+		if err != nil {
+			return err
+		}
+		targetID = user.ID
+	}
+
+	entries, err := bot.db.GetAuditLog(targetID, auditLogSize)
+	if err != nil {
+		return fmt.Errorf("failed to get audit log: %v", err)
+	}
+
+	var msg auditMessage
+	for _, entry := range entries {
+		msg.Entries = append(msg.Entries, auditLine{
+			Time:    niceTime(entry.CreatedAt.UTC()),
+			Action:  entry.Action,
+			Target:  bot.userName(entry.TargetID),
+			Actor:   bot.userName(entry.ActorID),
+			Reason:  entry.Reason,
+			Message: entry.Message,
+		})
+	}
+
+	return bot.replyTemplateLines(ctx, "audit", msg)
+}
@@ -0,0 +1,161 @@
+package auction_butler
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+const (
+	captchaOptions       = 4
+	captchaCheckInterval = 10 * time.Second
+)
+
+func (bot *Bot) captchaEnabled() bool {
+	return bot.config.Captcha.Timeout.Duration > 0
+}
+
+// Restricts the new member and posts a challenge that only they can solve.
+// The member gets enlisted once they solve it, see handleCallbackCaptcha.
+func (bot *Bot) challengeUser(dbuser *User) error {
+	if err := bot.restrict(dbuser.ID, false, time.Time{}); err != nil {
+		return fmt.Errorf("failed to restrict new member: %v", err)
+	}
+
+	a, b := rand.Intn(9)+1, rand.Intn(9)+1
+	answer := a + b
+	// the answer and some wrong ones close to it
+	options := []int{answer}
+	for _, i := range rand.Perm(2 * captchaOptions) {
+		if len(options) == captchaOptions {
+			break
+		}
+		offset := i - captchaOptions
+		if offset >= 0 {
+			offset++
+		}
+		options = append(options, answer+offset)
+	}
+
+	var buttons []tgbotapi.InlineKeyboardButton
+	for _, i := range rand.Perm(len(options)) {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(
+			strconv.Itoa(options[i]),
+			fmt.Sprintf("captcha:%d:%d", dbuser.ID, options[i]),
+		))
+	}
+
+	timeout := bot.config.Captcha.Timeout.Duration
+	msg := tgbotapi.NewMessage(bot.config.ChatID, bot.text(dbuser, "captcha", captchaMessage{
+		Name:    dbuser.Name(),
+		A:       a,
+		B:       b,
+		Timeout: niceDuration(timeout),
+	}))
+	msg.ParseMode = "HTML"
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(buttons...))
+	sent, err := bot.telegram.Send(msg)
+	if err != nil {
+		return fmt.Errorf("failed to send captcha: %v", err)
+	}
+
+	return bot.db.PutCaptcha(&Captcha{
+		UserID:    dbuser.ID,
+		MessageID: sent.MessageID,
+		Answer:    answer,
+		ExpiresAt: bot.clock.Now().Add(timeout),
+	})
+}
+
+// Handles the answer buttons of a captcha, the arguments are "user:answer".
+func (bot *Bot) handleCallbackCaptcha(ctx *Context, query *tgbotapi.CallbackQuery, args string) error {
+	parts := strings.Split(args, ":")
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid captcha answer: %s", args)
+	}
+	userID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return fmt.Errorf("invalid captcha answer: %s", args)
+	}
+	answer, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return fmt.Errorf("invalid captcha answer: %s", args)
+	}
+
+	if query.From.ID != userID {
+		return fmt.Errorf("this challenge is for someone else")
+	}
+
+	captcha := bot.db.GetCaptcha(userID)
+	if captcha == nil {
+		return fmt.Errorf("challenge expired")
+	}
+
+	if answer != captcha.Answer {
+		log.Printf("captcha failed: %s", ctx.User.NameAndTags())
+		return bot.failCaptcha(captcha)
+	}
+
+	if err := bot.db.DeleteCaptcha(userID); err != nil {
+		return fmt.Errorf("failed to delete captcha: %v", err)
+	}
+	bot.DeleteMsg(bot.config.ChatID, captcha.MessageID)
+
+	if err := bot.restrict(userID, true, time.Time{}); err != nil {
+		return fmt.Errorf("failed to lift restrictions: %v", err)
+	}
+
+	ctx.User.Enlisted = true
+	if err := bot.db.PutUser(ctx.User); err != nil {
+		return fmt.Errorf("failed to save the user: %v", err)
+	}
+
+	log.Printf("captcha solved: %s", ctx.User.NameAndTags())
+	return bot.welcome(ctx, "yell")
+}
+
+// Kicks the user who did not solve the captcha. They may join again and
+// try once more.
+func (bot *Bot) failCaptcha(captcha *Captcha) error {
+	if err := bot.db.DeleteCaptcha(captcha.UserID); err != nil {
+		return fmt.Errorf("failed to delete captcha: %v", err)
+	}
+	bot.DeleteMsg(bot.config.ChatID, captcha.MessageID)
+
+	member := bot.chatMember(captcha.UserID)
+	if _, err := bot.telegram.KickChatMember(tgbotapi.KickChatMemberConfig{ChatMemberConfig: member}); err != nil {
+		return fmt.Errorf("failed to kick: %v", err)
+	}
+	if _, err := bot.telegram.UnbanChatMember(member); err != nil {
+		return fmt.Errorf("failed to unban after kicking: %v", err)
+	}
+	bot.logMembership(captcha.UserID, memberKick, bot.telegram.Self.ID)
+
+	log.Printf("kicked %s for not solving the captcha", bot.userName(captcha.UserID))
+	return nil
+}
+
+// Kicks everyone whose captcha has expired. The challenges are stored in the
+// database, so members who joined before a restart are handled too.
+func (bot *Bot) watchCaptchas() {
+	if !bot.captchaEnabled() {
+		return
+	}
+
+	for {
+		captchas, err := bot.db.GetExpiredCaptchas()
+		if err != nil {
+			log.Printf("failed to get expired captchas: %v", err)
+		}
+		for i := range captchas {
+			if err := bot.failCaptcha(&captchas[i]); err != nil {
+				log.Printf("failed to kick user %d: %v", captchas[i].UserID, err)
+			}
+		}
+		time.Sleep(captchaCheckInterval)
+	}
+}
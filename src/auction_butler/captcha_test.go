@@ -0,0 +1,74 @@
+package auction_butler
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+func selfJoin(t *testing.T, bot *Bot, joiner tgbotapi.User) {
+	t.Helper()
+	err := bot.handleUpdate(&tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID:      1,
+		From:           &joiner,
+		Chat:           &tgbotapi.Chat{ID: bot.config.ChatID, Type: "supergroup"},
+		NewChatMembers: &[]tgbotapi.User{joiner},
+	}})
+	if err != nil {
+		t.Fatalf("failed to handle the join: %v", err)
+	}
+}
+
+// Someone joining by themselves is unknown to the bot and has to solve a
+// captcha before they may post.
+func TestSelfJoinCaptcha(t *testing.T) {
+	config := testConfig()
+	config.Captcha.Timeout = NewDuration(2 * time.Minute)
+	bot, clock, telegram := newTestBot(t, config)
+
+	joiner := tgbotapi.User{ID: 42, UserName: "spammer", FirstName: "Spam"}
+	selfJoin(t, bot, joiner)
+
+	captcha := bot.db.GetCaptcha(joiner.ID)
+	if captcha == nil {
+		t.Fatal("no captcha issued")
+	}
+	if want := clock.Now().Add(2 * time.Minute); !captcha.ExpiresAt.Equal(want) {
+		t.Errorf("captcha expires at %v, want %v", captcha.ExpiresAt, want)
+	}
+	if user := bot.db.GetUser(joiner.ID); user == nil || user.Enlisted || !user.JoinedAt.Time.Equal(clock.Now()) {
+		t.Errorf("joined user should be saved unenlisted with the join time: %+v", user)
+	}
+	if sent := telegram.Sent(); len(sent) != 1 {
+		t.Errorf("sent %q, want only the captcha", sent)
+	}
+}
+
+// Failing the captcha gets the user kicked, and they have to solve a new one
+// when they join again.
+func TestRejoinAfterFailedCaptcha(t *testing.T) {
+	config := testConfig()
+	config.Captcha.Timeout = NewDuration(2 * time.Minute)
+	bot, _, telegram := newTestBot(t, config)
+
+	joiner := tgbotapi.User{ID: 42, UserName: "spammer", FirstName: "Spam"}
+	selfJoin(t, bot, joiner)
+	if err := bot.failCaptcha(bot.db.GetCaptcha(joiner.ID)); err != nil {
+		t.Fatalf("failed to fail the captcha: %v", err)
+	}
+	if bot.db.GetCaptcha(joiner.ID) != nil {
+		t.Fatal("failed captcha not removed")
+	}
+
+	selfJoin(t, bot, joiner)
+	if bot.db.GetCaptcha(joiner.ID) == nil {
+		t.Fatal("no captcha issued on rejoin")
+	}
+	if user := bot.db.GetUser(joiner.ID); user == nil || user.Enlisted {
+		t.Errorf("rejoined user should stay unenlisted: %+v", user)
+	}
+	if sent := telegram.Sent(); len(sent) != 2 {
+		t.Errorf("sent %q, want two captchas and no welcome", sent)
+	}
+}
@@ -0,0 +1,95 @@
+package auction_butler
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Messages up to this many characters can count as emoji-only
+const maxEmojiLength = 16
+
+// Telegram mutes forever if the restriction ends in less than 30 seconds, so
+// an unset duration gets this
+const defaultMuteDuration = time.Hour
+
+// Tells whether the message consists of emojis and spaces only.
+func isEmojiOnly(text string) bool {
+	text = strings.TrimSpace(text)
+	if text == "" || len([]rune(text)) > maxEmojiLength {
+		return false
+	}
+	for _, r := range text {
+		if !unicode.In(r, unicode.So, unicode.Sk, unicode.Mn, unicode.Cf, unicode.White_Space) {
+			return false
+		}
+	}
+	return true
+}
+
+func (bot *Bot) isWhitelisted(text string) bool {
+	text = strings.ToLower(strings.TrimSpace(text))
+	for _, allowed := range bot.config.Chat.Whitelist {
+		if text == strings.ToLower(allowed) {
+			return true
+		}
+	}
+	return bot.config.Chat.AllowEmoji && isEmojiOnly(text)
+}
+
+func (bot *Bot) isReplyToStaff(ctx *Context) bool {
+	re := ctx.message.ReplyToMessage
+	if re == nil || re.From == nil {
+		return false
+	}
+	user := bot.db.GetUser(re.From.ID)
+	return user != nil && user.IsStaff()
+}
+
+// Tells whether a message that is not a bid may stay in the group. During
+// the countdown only bids are allowed.
+func (bot *Bot) chatterAllowed(ctx *Context) bool {
+	if bot.countdownRunning() {
+		return false
+	}
+	policy := bot.config.Chat
+	if policy.AllowChatter {
+		return true
+	}
+	if policy.AllowStaffReplies && bot.isReplyToStaff(ctx) {
+		return true
+	}
+	return bot.isWhitelisted(ctx.message.Text)
+}
+
+// Warns the user about a deleted message, or mutes them once they have been
+// warned often enough.
+func (bot *Bot) chatViolation(ctx *Context) {
+	policy := bot.config.Chat
+	if policy.MuteAfter <= 0 {
+		return
+	}
+
+	user := ctx.User
+	count := bot.chatViolations[user.ID] + 1
+	if count < policy.MuteAfter {
+		bot.chatViolations[user.ID] = count
+		bot.Whisper(user.ID, "html", bot.text(user, "chat_warning", chatWarningMessage{policy.MuteAfter - count}))
+		return
+	}
+	delete(bot.chatViolations, user.ID)
+
+	duration := policy.MuteFor.Duration
+	if duration <= 0 {
+		duration = defaultMuteDuration
+	}
+	until := bot.clock.Now().Add(duration)
+	if err := bot.restrict(user.ID, false, until); err != nil {
+		log.Printf("failed to mute %s: %v", user.NameAndTags(), err)
+		return
+	}
+	bot.audit(nil, user.ID, auditMute, "too many messages that are not bids", "")
+	bot.Whisper(user.ID, "html", bot.text(user, "chat_muted", chatMutedMessage{bot.userTime(user, until)}))
+
+	log.Printf("muted until %s: %s", until.UTC(), user.NameAndTags())
+}
@@ -0,0 +1,170 @@
+package auction_butler
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// A clock that only moves when told to. Timers fire as soon as the clock
+// reaches them, and every armed timer is reported on armed, so that tests
+// can tell when the code under test is waiting.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+	armed  chan time.Time
+}
+
+type fakeTimer struct {
+	clock  *fakeClock
+	c      chan time.Time
+	at     time.Time
+	active bool
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now, armed: make(chan time.Time, 1000)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	c.mu.Lock()
+	c.timers = append(c.timers, t)
+	c.mu.Unlock()
+	t.Reset(d)
+	return t
+}
+
+// Moves the clock forward by d, firing the timers on the way.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.fire()
+}
+
+// Moves the clock to the earliest active timer and fires it. Returns false if
+// there is no active timer.
+func (c *fakeClock) AdvanceToNext() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var next *fakeTimer
+	for _, t := range c.timers {
+		if t.active && (next == nil || t.at.Before(next.at)) {
+			next = t
+		}
+	}
+	if next == nil {
+		return false
+	}
+	if next.at.After(c.now) {
+		c.now = next.at
+	}
+	c.fire()
+	return true
+}
+
+// Waits until the code under test arms a timer, returning when it is due.
+func (c *fakeClock) WaitArmed(t *testing.T) time.Time {
+	t.Helper()
+	select {
+	case at := <-c.armed:
+		return at
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a timer to be armed")
+		return time.Time{}
+	}
+}
+
+// Fires the timers that are due, the caller holds the lock.
+func (c *fakeClock) fire() {
+	for _, t := range c.timers {
+		if t.active && !t.at.After(c.now) {
+			t.active = false
+			select {
+			case t.c <- t.at:
+			default:
+			}
+		}
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := t.active
+	t.active = false
+	return active
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	active := t.active
+	t.at = c.now.Add(d)
+	t.active = true
+	select {
+	case c.armed <- t.at:
+	default:
+	}
+	c.fire()
+	return active
+}
+
+func TestFakeClockFiresTimersInOrder(t *testing.T) {
+	start := time.Date(2018, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	late := clock.NewTimer(time.Hour)
+	early := clock.NewTimer(time.Minute)
+
+	if !clock.AdvanceToNext() {
+		t.Fatal("expected an active timer")
+	}
+	if at := <-early.C(); !at.Equal(start.Add(time.Minute)) {
+		t.Fatalf("early timer fired at %v", at)
+	}
+	select {
+	case <-late.C():
+		t.Fatal("late timer fired too early")
+	default:
+	}
+
+	clock.Advance(time.Hour)
+	if at := <-late.C(); !at.Equal(start.Add(time.Hour)) {
+		t.Fatalf("late timer fired at %v", at)
+	}
+	if clock.AdvanceToNext() {
+		t.Fatal("expected no active timer")
+	}
+}
+
+func TestResetTimerForgetsFiredTick(t *testing.T) {
+	clock := newFakeClock(time.Date(2018, 5, 1, 12, 0, 0, 0, time.UTC))
+	timer := clock.NewTimer(0)
+
+	resetTimer(timer, time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("tick from before the reset was seen")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case <-timer.C():
+	default:
+		t.Fatal("timer did not fire after the reset")
+	}
+}
@@ -9,7 +9,8 @@ import (
 )
 
 type Command struct {
-	Admin       bool
+	// what the user needs to be allowed to, see rolePermissions
+	Permission  string
 	Command     string
 	Handlerfunc CommandHandler
 }
@@ -18,7 +19,10 @@ type Commands []Command
 
 func (bot *Bot) setCommandHandlers() {
 	for _, command := range commands {
-		bot.SetCommandHandler(command.Admin, command.Command, command.Handlerfunc)
+		bot.SetCommandHandler(command.Permission, command.Command, command.Handlerfunc)
+	}
+	for name, handler := range callbacks {
+		bot.callbackHandlers[name] = handler
 	}
 }
 
@@ -32,32 +36,21 @@ func (bot *Bot) AddGroupMessageHandler(handler MessageHandler) {
 
 // Handler for help command
 func (bot *Bot) handleCommandHelp(ctx *Context, command, args string) error {
-	// Indentation messes up how the text is shown in chat.
-	if ctx.User.Admin {
-		return bot.Reply(ctx, `
-/start
-/help - this text
-/setauctioninfo [end_time] [auction_info](optional) - set details for current auction
-/getauctioninfo - returns info of current auction
-`)
-	}
-
-	return bot.Reply(ctx, `
-/start
-/help - this text
-/getauctioninfo - returns info of current auction`)
+	return bot.ReplyTemplate(ctx, "help", helpMessage{
+		Bid:      ctx.User.Can(permBid),
+		Auction:  ctx.User.Can(permAuction),
+		Moderate: ctx.User.Can(permModerate),
+		Manage:   ctx.User.Can(permManage),
+	})
 }
 
 func (bot *Bot) handleSetAuctionInfo(ctx *Context, command, args string) error {
-	end, err := parseStartAuctioArgs(args)
-	fmt.Println("comes here for auction settings")
+	end, err := parseStartAuctioArgs(args, bot.clock.Now(), bot.zone(ctx.User))
 	if err != nil {
 		return fmt.Errorf("could not understand: %v", err)
 	}
 
-	bot.runningCountDown = false
-	bot.Reschedule()
-	return bot.db.PutAuction(end)
+	return bot.askEndTimeConfirmation(ctx, end)
 }
 
 func (bot *Bot) handleGetAuctionInfo(ctx *Context, command, args string) error {
@@ -65,16 +58,26 @@ func (bot *Bot) handleGetAuctionInfo(ctx *Context, command, args string) error {
 	if auction == nil {
 		return errors.New("No auction found")
 	}
-    return bot.Reply(ctx, fmt.Sprintf(`Auction End Time: %s`,  auction.EndTime.Time.UTC().String()))
+    return bot.ReplyTemplate(ctx, "auction_info", endTimeMessage{bot.userTime(ctx.User, auction.EndTime.Time)})
 }
 
-func parseStartAuctioArgs(args string) (end time.Time, err error) {
+// Parses the end time of an auction. Times without a zone are in the given
+// one.
+func parseStartAuctioArgs(args string, now time.Time, defaultZone *time.Location) (end time.Time, err error) {
 	words := strings.Fields(args)
 	if len(words) == 0 {
 		err = fmt.Errorf("insufficient arguments")
 		return
 	}
 
+	if d, relative, rerr := parseRelativeTime(words); relative {
+		return now.Add(d).UTC(), rerr
+	}
+	zone, words, err := extractZone(words)
+	if err != nil {
+		return
+	}
+
 	timestr := strings.Join(words, " ")
 	ft, _, err := fuzzytime.Extract(timestr)
 	if ft.Empty() {
@@ -95,8 +98,10 @@ func parseStartAuctioArgs(args string) (end time.Time, err error) {
 	}
 	if ft.Time.HasTZOffset() {
 		loc = time.FixedZone("", ft.Time.TZOffset())
+	} else if zone != nil {
+		loc = zone
 	} else {
-		loc = time.UTC
+		loc = defaultZone
 	}
 
 	if ft.HasFullDate() {
@@ -108,18 +113,18 @@ func parseStartAuctioArgs(args string) (end time.Time, err error) {
 			loc,
 		)
 	} else {
-		year, month, day := time.Now().In(loc).Date()
+		year, month, day := now.In(loc).Date()
 		end = time.Date(
 			year, month, day,
 			hour, minute, second, 0,
 			loc,
 		)
-		if end.Before(time.Now()) {
+		if end.Before(now) {
 			end = end.AddDate(0, 0, 1)
 		}
 	}
 
-	if end.Before(time.Now()) {
+	if end.Before(now) {
 		err = fmt.Errorf("%s is in the past", end.String())
 		return
 	}
@@ -127,28 +132,196 @@ func parseStartAuctioArgs(args string) (end time.Time, err error) {
 	return
 }
 
-func (bot *Bot) SetCommandHandler(admin bool, command string, handler CommandHandler) {
-	if admin {
-		bot.adminCommandHandlers[command] = handler
-	} else {
-		bot.commandHandlers[command] = handler
-	}
+func (bot *Bot) SetCommandHandler(permission, command string, handler CommandHandler) {
+	bot.commandHandlers[command] = Command{permission, command, handler}
 }
 
 var commands = Commands{
 	Command{
-		false,
+		permView,
 		"help",
 		(*Bot).handleCommandHelp,
 	},
 	Command{
-		false,
+		permView,
 		"getauctioninfo",
 		(*Bot).handleGetAuctionInfo,
 	},
 	Command{
-		true,
+		permView,
+		"results",
+		(*Bot).handleCommandResults,
+	},
+	Command{
+		permView,
+		"auction",
+		(*Bot).handleCommandAuction,
+	},
+	Command{
+		permBid,
+		"mybids",
+		(*Bot).handleCommandMyBids,
+	},
+	Command{
+		permBid,
+		"mywins",
+		(*Bot).handleCommandMyWins,
+	},
+	Command{
+		permBid,
+		"mystats",
+		(*Bot).handleCommandMyStats,
+	},
+	Command{
+		permView,
+		"leaderboard",
+		(*Bot).handleCommandLeaderboard,
+	},
+	Command{
+		permView,
+		"language",
+		(*Bot).handleCommandLanguage,
+	},
+	Command{
+		permView,
+		"timezone",
+		(*Bot).handleCommandTimezone,
+	},
+	Command{
+		permAuction,
+		"stats",
+		(*Bot).handleCommandStats,
+	},
+	Command{
+		permAuction,
 		"setauctioninfo",
 		(*Bot).handleSetAuctionInfo,
 	},
+	Command{
+		permAuction,
+		"pauseauction",
+		(*Bot).handleCommandPauseAuction,
+	},
+	Command{
+		permAuction,
+		"resumeauction",
+		(*Bot).handleCommandResumeAuction,
+	},
+	Command{
+		permAuction,
+		"extend",
+		(*Bot).handleCommandExtend,
+	},
+	Command{
+		permAuction,
+		"cancelauction",
+		(*Bot).handleCommandCancelAuction,
+	},
+	Command{
+		permAuction,
+		"reminders",
+		(*Bot).handleCommandReminders,
+	},
+	Command{
+		permAuction,
+		"setreminders",
+		(*Bot).handleCommandSetReminders,
+	},
+	Command{
+		permAuction,
+		"recurring",
+		(*Bot).handleCommandRecurring,
+	},
+	Command{
+		permAuction,
+		"addrecurring",
+		(*Bot).handleCommandAddRecurring,
+	},
+	Command{
+		permAuction,
+		"pauserecurring",
+		(*Bot).handleCommandPauseRecurring,
+	},
+	Command{
+		permAuction,
+		"resumerecurring",
+		(*Bot).handleCommandResumeRecurring,
+	},
+	Command{
+		permAuction,
+		"deleterecurring",
+		(*Bot).handleCommandDeleteRecurring,
+	},
+	Command{
+		permModerate,
+		"strike",
+		(*Bot).handleCommandStrike,
+	},
+	Command{
+		permModerate,
+		"strikes",
+		(*Bot).handleCommandStrikes,
+	},
+	Command{
+		permModerate,
+		"pardon",
+		(*Bot).handleCommandPardon,
+	},
+	Command{
+		permModerate,
+		"ban",
+		(*Bot).handleCommandBan,
+	},
+	Command{
+		permModerate,
+		"unban",
+		(*Bot).handleCommandUnban,
+	},
+	Command{
+		permManage,
+		"promote",
+		(*Bot).handleCommandPromote,
+	},
+	Command{
+		permManage,
+		"demote",
+		(*Bot).handleCommandDemote,
+	},
+	Command{
+		permModerate,
+		"users",
+		(*Bot).handleCommandUsers,
+	},
+	Command{
+		permModerate,
+		"whois",
+		(*Bot).handleCommandWhois,
+	},
+	Command{
+		permModerate,
+		"audit",
+		(*Bot).handleCommandAudit,
+	},
+	Command{
+		permManage,
+		"jobs",
+		(*Bot).handleCommandJobs,
+	},
+	Command{
+		permManage,
+		"role",
+		(*Bot).handleCommandRole,
+	},
+	Command{
+		permModerate,
+		"roles",
+		(*Bot).handleCommandRoles,
+	},
+}
+
+// Inline keyboard callbacks by the name before the colon in the callback data
+var callbacks = map[string]CallbackHandler{
+	"results":    (*Bot).handleCallbackResults,
+	"captcha":    (*Bot).handleCallbackCaptcha,
+	"setauction": (*Bot).handleCallbackSetAuction,
 }
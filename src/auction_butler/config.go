@@ -5,14 +5,94 @@ type DatabaseConfig struct {
 	Source string `json:"source"`
 }
 
+type PaymentConfig struct {
+	CheckInterval   Duration          `json:"check_interval"`
+	Addresses       map[string]string `json:"addresses"`
+	Confirmations   map[string]int    `json:"confirmations"`
+	SkycoinNode     string            `json:"skycoin_node"`
+	BitcoinExplorer string            `json:"bitcoin_explorer"`
+}
+
+type StrikesConfig struct {
+	// suspend bidding once a user has this many strikes (0 disables)
+	SuspendAfter int      `json:"suspend_after"`
+	SuspendFor   Duration `json:"suspend_for"`
+	// ban once a user has this many strikes (0 disables)
+	BanAfter int `json:"ban_after"`
+	// give a strike to winners who have not paid for this long after the end
+	PaymentDeadline Duration `json:"payment_deadline"`
+}
+
+type CaptchaConfig struct {
+	// how long new members have to solve the captcha (0 disables it)
+	Timeout Duration `json:"timeout"`
+}
+
+// When to remind the group that the auction is ending, relative to its end.
+// Offsets take precedence over the decay rule, which takes precedence over
+// reminding every so often.
+type ReminderPlan struct {
+	// remind this long before the end, e.g. ["24h", "1h", "10m"]
+	Offsets []Duration `json:"offsets,omitempty"`
+	// remind First before the end, then each time the time left shrank by
+	// Factor, as long as it is at least Min
+	Decay *ReminderDecay `json:"decay,omitempty"`
+	// remind every so long
+	Every Duration `json:"every,omitempty"`
+}
+
+type ReminderDecay struct {
+	First  Duration `json:"first"`
+	Factor float64  `json:"factor"`
+	Min    Duration `json:"min"`
+}
+
+// What members may post in the group besides bids. During the countdown only
+// bids are allowed regardless.
+type ChatConfig struct {
+	// allow any message outside the countdown
+	AllowChatter bool `json:"allow_chatter"`
+	// allow replies to admins, auctioneers and moderators
+	AllowStaffReplies bool `json:"allow_staff_replies"`
+	// allow these short messages, compared case insensitively
+	Whitelist []string `json:"whitelist"`
+	// allow messages made of emojis only
+	AllowEmoji bool `json:"allow_emoji"`
+	// mute members after this many deleted messages, warning them before (0 disables)
+	MuteAfter int      `json:"mute_after"`
+	MuteFor   Duration `json:"mute_for"`
+}
+
+type AdminsConfig struct {
+	// how often to copy admin rights from the group's administrators (0 disables)
+	SyncInterval Duration `json:"sync_interval"`
+	// ids of users who are bot admins without being group administrators
+	BotOnly []int `json:"bot_only"`
+}
+
 type Config struct {
 	Debug                    bool           `json:"debug"`
 	Token                    string         `json:"token"`
 	ChatID                   int64          `json:"chat_id"`
 	Database                 DatabaseConfig `json:"database"`
 	ReminderAnnounceInterval Duration       `json:"reminder_announce_interval"`
+	Reminders                ReminderPlan   `json:"reminders"`
 	CountdownFrom            int64          `json:"countdown_from"`
 	ResettingCountdownFrom   int64          `json:"resetting_countdown_from"`
+	LiveCountdown            bool           `json:"live_countdown"`
 	MsgDeleteCounter         Duration       `json:"msg_destroy_counter"`
 	ConversionFactor         int64          `json:"conversion_factor"`
+	Payment                  PaymentConfig  `json:"payment"`
+	Strikes                  StrikesConfig  `json:"strikes"`
+	Captcha                  CaptchaConfig  `json:"captcha"`
+	Admins                   AdminsConfig   `json:"admins"`
+	Chat                     ChatConfig     `json:"chat"`
+	// templates to use instead of the built-in messages
+	MessagesFile string `json:"messages_file"`
+	// language of group messages and of users without a known preference
+	Language string `json:"language"`
+	// time zone of group announcements and of users who have not set one
+	Timezone string `json:"timezone"`
+	// message templates of other languages by language code
+	Translations map[string]string `json:"translations"`
 }
@@ -0,0 +1,115 @@
+package auction_butler
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (bot *Bot) handleCommandPauseAuction(ctx *Context, command, args string) error {
+	auction := bot.db.GetCurrentAuction()
+	if auction == nil {
+		return fmt.Errorf("no ongoing auction")
+	}
+	if auction.Phase == auctionPaused {
+		return fmt.Errorf("auction #%d is paused already", auction.ID)
+	}
+
+	remaining := auction.EndTime.Time.Sub(bot.clock.Now())
+	if remaining < 0 {
+		remaining = 0
+	}
+	if err := bot.db.PauseAuction(auction.ID, remaining); err != nil {
+		return fmt.Errorf("failed to pause auction: %v", err)
+	}
+	bot.Reschedule()
+
+	log.Printf("auction #%d paused with %v left", auction.ID, remaining)
+	bot.SendTemplate(&Context{}, "yell", "auction_paused", nil)
+	return bot.ReplyTemplate(ctx, "paused", controlMessage{ID: auction.ID, Left: niceDuration(remaining)})
+}
+
+func (bot *Bot) handleCommandResumeAuction(ctx *Context, command, args string) error {
+	auction := bot.db.GetCurrentAuction()
+	if auction == nil {
+		return fmt.Errorf("no ongoing auction")
+	}
+	if auction.Phase != auctionPaused {
+		return fmt.Errorf("auction #%d is not paused", auction.ID)
+	}
+
+	// a paused countdown goes on from where it stopped
+	phase := auctionOpen
+	if auction.Countdown > 0 {
+		phase = auctionCountdown
+	}
+	end := bot.clock.Now().Add(auction.Remaining.Duration)
+	if err := bot.db.SetAuctionEnd(auction.ID, end, phase, auction.Countdown); err != nil {
+		return fmt.Errorf("failed to resume auction: %v", err)
+	}
+	bot.Reschedule()
+
+	log.Printf("auction #%d resumed", auction.ID)
+	bot.SendTemplate(&Context{}, "yell", "auction_resumed", endTimeMessage{bot.groupTime(end)})
+	return bot.ReplyTemplate(ctx, "resumed", controlMessage{ID: auction.ID, EndTime: bot.userTime(ctx.User, end)})
+}
+
+func (bot *Bot) handleCommandExtend(ctx *Context, command, args string) error {
+	auction := bot.db.GetCurrentAuction()
+	if auction == nil {
+		return fmt.Errorf("no ongoing auction")
+	}
+	by, err := parseDuration(strings.TrimSpace(args))
+	if err != nil || by <= 0 {
+		return fmt.Errorf("could not understand: give how long to extend by, e.g. 30m")
+	}
+
+	if auction.Phase == auctionPaused {
+		remaining := auction.Remaining.Duration + by
+		if err := bot.db.PauseAuction(auction.ID, remaining); err != nil {
+			return fmt.Errorf("failed to extend auction: %v", err)
+		}
+		return bot.ReplyTemplate(ctx, "extended", controlMessage{ID: auction.ID, Left: niceDuration(remaining), Paused: true})
+	}
+
+	// a running countdown stops and starts over when the new end is near
+	end := auction.EndTime.Time.Add(by)
+	if err := bot.db.SetAuctionEnd(auction.ID, end, auctionOpen, 0); err != nil {
+		return fmt.Errorf("failed to extend auction: %v", err)
+	}
+	bot.Reschedule()
+
+	log.Printf("auction #%d extended by %v", auction.ID, by)
+	bot.SendTemplate(&Context{}, "yell", "auction_extended", endTimeMessage{bot.groupTime(end)})
+	return bot.ReplyTemplate(ctx, "extended", controlMessage{ID: auction.ID, EndTime: bot.userTime(ctx.User, end)})
+}
+
+func (bot *Bot) handleCommandCancelAuction(ctx *Context, command, args string) error {
+	auction := bot.db.GetCurrentAuction()
+	if auction == nil {
+		return fmt.Errorf("no ongoing auction")
+	}
+	reason := strings.TrimSpace(args)
+	if reason == "" {
+		return fmt.Errorf("insufficient arguments: give a reason")
+	}
+
+	if err := bot.db.CancelAuction(auction.ID, reason); err != nil {
+		return fmt.Errorf("failed to cancel auction: %v", err)
+	}
+	bot.swapLastBidMessage(nil)
+	bot.Reschedule()
+
+	log.Printf("auction #%d cancelled: %s", auction.ID, reason)
+	msg := cancelledMessage{ID: auction.ID, Reason: reason}
+	bot.runOrRetry(jobYell, yellJob{bot.text(nil, "auction_cancelled", msg)})
+
+	bidders, err := bot.db.GetAuctionBidders(auction.ID)
+	if err != nil {
+		return fmt.Errorf("auction cancelled, but failed to get its bidders: %v", err)
+	}
+	for _, id := range bidders {
+		bot.runOrRetry(jobWhisper, whisperJob{id, bot.text(bot.db.GetUser(id), "auction_cancelled", msg)})
+	}
+
+	return bot.ReplyTemplate(ctx, "cancelled", controlMessage{ID: auction.ID, Bidders: len(bidders)})
+}
@@ -0,0 +1,214 @@
+package auction_butler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+// A running countdown. It belongs to the maintain goroutine, which moves it
+// on when its timer fires.
+type countdown struct {
+	auctionID int
+	// the number to announce next
+	count int64
+	timer Timer
+	// the pinned message that gets edited on every tick, with live_countdown.
+	// It is stored with the auction, like previousPin.
+	messageID int
+	// the message that was pinned before, to pin it again afterwards
+	previousPin int
+}
+
+// Starts counting down from the given number, the first one is announced
+// right away.
+func (bot *Bot) startCountdown(auctionID int, from int64) *countdown {
+	if err := bot.db.SetAuctionPhase(auctionID, auctionCountdown, from); err != nil {
+		log.Printf("failed to start the countdown of auction #%d: %v", auctionID, err)
+		return nil
+	}
+	bot.setCountdownRunning(true)
+
+	log.Printf("countdown of auction #%d started at %d", auctionID, from)
+	cd := &countdown{
+		auctionID: auctionID,
+		count:     from,
+		timer:     bot.clock.NewTimer(0),
+	}
+	if bot.config.LiveCountdown {
+		previous, err := bot.pinnedMessage()
+		if err != nil {
+			log.Printf("failed to get the pinned message: %v", err)
+		}
+		cd.previousPin = previous
+	}
+	return cd
+}
+
+// Announces the next number and waits for the one after, or closes the
+// auction once the countdown reached zero.
+func (bot *Bot) tick(cd *countdown) *countdown {
+	if cd.count <= 0 {
+		bot.stopCountdown(cd)
+		if err := bot.closeAuction(cd.auctionID); err != nil {
+			log.Printf("failed to close auction #%d: %v", cd.auctionID, err)
+		}
+		return nil
+	}
+
+	// stored so that a restart can go on from here
+	if err := bot.db.SetAuctionCountdown(cd.auctionID, cd.count); err != nil {
+		log.Printf("failed to save countdown: %v", err)
+	}
+	if bot.config.LiveCountdown {
+		bot.showCountdown(cd)
+	} else {
+		bot.SendTemplate(&Context{}, "yell", "countdown", countdownMessage{cd.count})
+	}
+
+	step := countdownStep
+	if cd.count <= bot.config.ResettingCountdownFrom {
+		step = resettingCountdownStep
+	}
+	cd.count--
+	resetTimer(cd.timer, step)
+	return cd
+}
+
+// Puts a countdown that got below the given number back to it, as a bid came
+// in. The number is announced after the usual pause.
+func (cd *countdown) reset(to int64) {
+	if cd.count >= to {
+		return
+	}
+	cd.count = to
+	resetTimer(cd.timer, resettingCountdownStep)
+}
+
+// Goes on with the countdown unless the auction got paused, extended or
+// cancelled in the meantime.
+func (bot *Bot) checkCountdown(cd *countdown) *countdown {
+	auction := bot.db.GetAuction(cd.auctionID)
+	if auction != nil && auction.Phase == auctionCountdown {
+		return cd
+	}
+	bot.stopCountdown(cd)
+	log.Printf("countdown of auction #%d interrupted", cd.auctionID)
+	return nil
+}
+
+func (bot *Bot) stopCountdown(cd *countdown) {
+	if cd == nil {
+		return
+	}
+	cd.timer.Stop()
+	bot.setCountdownRunning(false)
+	bot.removeCountdownMessage(cd.auctionID, cd.messageID, cd.previousPin)
+	cd.messageID = 0
+}
+
+// Unpins and deletes the live countdown message of the auction, if it has
+// one, and pins the message that was pinned before again.
+func (bot *Bot) removeCountdownMessage(auctionID, messageID, previousPin int) {
+	if messageID == 0 {
+		return
+	}
+	if _, err := bot.telegram.UnpinChatMessage(tgbotapi.UnpinChatMessageConfig{ChatID: bot.config.ChatID}); err != nil {
+		log.Printf("failed to unpin the countdown message: %v", err)
+	}
+	bot.deleteCountdownMessage(messageID)
+	if previousPin != 0 {
+		if err := bot.pinMessage(previousPin); err != nil {
+			log.Printf("failed to pin message %d again: %v", previousPin, err)
+		}
+	}
+	if err := bot.db.SetAuctionCountdownMessage(auctionID, 0, 0); err != nil {
+		log.Printf("failed to forget the countdown message: %v", err)
+	}
+}
+
+// Updates the live countdown message, posting and pinning it first if there
+// is none. When editing fails a new message takes its place.
+func (bot *Bot) showCountdown(cd *countdown) {
+	msg := liveCountdownMessage{
+		Count: cd.count,
+		Left:  niceDuration(bot.countdownLeft(cd.count)),
+	}
+	if auction := bot.db.GetAuction(cd.auctionID); auction != nil && auction.BidderID != 0 {
+		bid := Bid{Value: auction.BidVal, CoinType: auction.BidType}
+		msg.Bid = bid.String()
+		msg.Leader = bot.userName(auction.BidderID)
+	}
+	text := bot.text(nil, "live_countdown", msg)
+
+	if cd.messageID != 0 {
+		edit := tgbotapi.NewEditMessageText(bot.config.ChatID, cd.messageID, text)
+		edit.ParseMode = "HTML"
+		_, err := bot.telegram.Send(edit)
+		if err == nil {
+			return
+		}
+		log.Printf("failed to edit the countdown message, posting a new one: %v", err)
+		bot.deleteCountdownMessage(cd.messageID)
+		cd.messageID = 0
+	}
+
+	sent, err := bot.Send(&Context{}, "yell", "html", text)
+	if err != nil {
+		log.Printf("failed to post the countdown message: %v", err)
+		return
+	}
+	cd.messageID = sent.MessageID
+	if err := bot.db.SetAuctionCountdownMessage(cd.auctionID, cd.messageID, cd.previousPin); err != nil {
+		log.Printf("failed to save the countdown message: %v", err)
+	}
+	if err := bot.pinMessage(cd.messageID); err != nil {
+		log.Printf("failed to pin the countdown message: %v", err)
+	}
+}
+
+// Returns the id of the message pinned in the group, zero if there is none.
+// The vendored Chat type predates pinned messages, so the reply is decoded
+// here.
+func (bot *Bot) pinnedMessage() (int, error) {
+	resp, err := bot.telegram.MakeRequest("getChat", url.Values{
+		"chat_id": {strconv.FormatInt(bot.config.ChatID, 10)},
+	})
+	if err != nil {
+		return 0, err
+	}
+	var chat struct {
+		PinnedMessage *tgbotapi.Message `json:"pinned_message"`
+	}
+	if err := json.Unmarshal(resp.Result, &chat); err != nil {
+		return 0, err
+	}
+	if chat.PinnedMessage == nil {
+		return 0, nil
+	}
+	return chat.PinnedMessage.MessageID, nil
+}
+
+func (bot *Bot) pinMessage(messageID int) error {
+	resp, err := bot.telegram.PinChatMessage(tgbotapi.PinChatMessageConfig{
+		ChatID:              bot.config.ChatID,
+		MessageID:           messageID,
+		DisableNotification: true,
+	})
+	if err == nil && !resp.Ok {
+		err = fmt.Errorf("%s", resp.Description)
+	}
+	return err
+}
+
+func (bot *Bot) deleteCountdownMessage(messageID int) {
+	if _, err := bot.telegram.DeleteMessage(tgbotapi.DeleteMessageConfig{
+		ChatID:    bot.config.ChatID,
+		MessageID: messageID,
+	}); err != nil {
+		log.Printf("failed to delete the countdown message: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package auction_butler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+func (bot *Bot) handleCommandMyBids(ctx *Context, command, args string) error {
+	bids, err := bot.db.GetUserActiveBids(ctx.User.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get your bids: %v", err)
+	}
+
+	var msg myBidsMessage
+	for _, placed := range bids {
+		auction := bot.db.GetAuctionResult(placed.AuctionID)
+		if auction == nil {
+			continue
+		}
+		bid := Bid{Value: placed.Value, CoinType: placed.CoinType}
+		current := Bid{Value: auction.BidVal, CoinType: auction.BidType}
+		msg.Bids = append(msg.Bids, myBidLine{
+			ID:      auction.ID,
+			EndTime: bot.localTime(ctx.User, auction.EndTime.Time),
+			Bid:     bid.String(),
+			Leading: auction.BidderID == ctx.User.ID,
+			Current: current.String(),
+		})
+	}
+
+	return bot.ReplyTemplate(ctx, "my_bids", msg)
+}
+
+func (bot *Bot) handleCommandMyWins(ctx *Context, command, args string) error {
+	wins, err := bot.db.GetWonAuctions(ctx.User.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get your wins: %v", err)
+	}
+
+	var msg myWinsMessage
+	for i := range wins {
+		msg.Wins = append(msg.Wins, bot.resultLine(ctx.User, &wins[i]))
+	}
+
+	return bot.ReplyTemplate(ctx, "my_wins", msg)
+}
+
+func (bot *Bot) handleCommandMyStats(ctx *Context, command, args string) error {
+	stats, err := bot.db.GetUserBidStats(ctx.User.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get your statistics: %v", err)
+	}
+	wins, err := bot.db.GetWonAuctions(ctx.User.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get your wins: %v", err)
+	}
+
+	spent := make(map[string]float64)
+	for _, auction := range wins {
+		spent[auction.BidType] += auction.BidVal
+	}
+	var totals []string
+	for coin, value := range spent {
+		totals = append(totals, fmt.Sprintf("%v %v", value, coin))
+	}
+	sort.Strings(totals)
+
+	msg := myStatsMessage{
+		Bids:     stats.Bids,
+		Auctions: stats.Auctions,
+		Wins:     len(wins),
+		Spent:    strings.Join(totals, ", "),
+	}
+	if stats.FirstBid.Valid {
+		msg.Since = bot.localTime(ctx.User, stats.FirstBid.Time)
+	}
+
+	return bot.ReplyTemplate(ctx, "my_stats", msg)
+}
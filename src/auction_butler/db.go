@@ -2,22 +2,93 @@ package auction_butler
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"database/sql"
 
+	"sort"
 	"strconv"
 
 	"github.com/jmoiron/sqlx"
 )
 
+// Store is what the bot keeps in the database. DB implements it on top of
+// postgres, the tests have an in-memory one.
+type Store interface {
+	GetUser(id int) *User
+	GetUserByName(name string) *User
+	GetUserByNameOrId(identifier string) *User
+	GetUsers(banned bool) ([]User, error)
+	GetAdmins() ([]User, error)
+	GetAllUsers() ([]User, error)
+	GetUsersWithRoles() ([]User, error)
+	GetUserCount(banned bool) (int, error)
+	GetCurrentAuction() *Auction
+	GetAuction(id int) *Auction
+	GetOpenAuctions() ([]Auction, error)
+	SetAuctionPhase(id int, phase string, countdown int64) error
+	SetAuctionCountdown(id int, countdown int64) error
+	PauseAuction(id int, remaining time.Duration) error
+	SetAuctionEnd(id int, end time.Time, phase string, countdown int64) error
+	CancelAuction(id int, reason string) error
+	GetAuctionBidders(id int) ([]int, error)
+	SetAuctionMessage(id int, messageID int) error
+	SetAuctionCountdownMessage(id int, messageID, previousPin int) error
+	PutAuction(end time.Time) error
+	PutScheduledAuction(a *Auction) error
+	DeleteUpcomingAuctions(recurringID int) ([]time.Time, error)
+	SetAuctionReminderPlan(id int, plan string) error
+	SetAuctionBid(id int, bid *Bid, bidderID int) error
+	PutBid(auctionID, userID int, bid *Bid) error
+	GetUserActiveBids(userID int) ([]PlacedBid, error)
+	GetWonAuctions(userID int) ([]Auction, error)
+	GetUserBidStats(userID int) (*UserBidStats, error)
+	GetLeaderboard(metric string, since time.Time, conversionFactor int64, limit int) ([]LeaderboardEntry, error)
+	GetAuctionStats(conversionFactor int64) (*AuctionStats, error)
+	GetEndedAuctions(limit, offset int) ([]AuctionResult, error)
+	GetEndedAuctionCount() (int, error)
+	GetAuctionResult(id int) *AuctionResult
+	GetUnsettledAuctions() ([]Auction, error)
+	SetAuctionPayment(id int, status string, confirmations int, txid string) error
+	GetClaimedPayments() ([]string, error)
+	EndAuction(id int) error
+	PutCaptcha(c *Captcha) error
+	GetCaptcha(userID int) *Captcha
+	GetExpiredCaptchas() ([]Captcha, error)
+	DeleteCaptcha(userID int) error
+	PutMembershipEvent(e *MembershipEvent) error
+	GetMembershipEvents(userID int, limit int) ([]MembershipEvent, error)
+	PutUsernameChange(userID int, username string) error
+	GetUsernameHistory(userID int) ([]UsernameChange, error)
+	PutAuditEntry(e *AuditEntry) error
+	GetAuditLog(targetID int, limit int) ([]AuditEntry, error)
+	PutStrike(s *Strike) error
+	GetStrikes(userID int) ([]Strike, error)
+	GetActiveStrikeCount(userID int) int
+	HasAuctionStrike(auctionID int) bool
+	PardonStrikes(userID int) (int, error)
+	PutUser(u *User) error
+	PutRecurringAuction(r *RecurringAuction) error
+	GetRecurringAuction(id int) *RecurringAuction
+	GetRecurringAuctions() ([]RecurringAuction, error)
+	SetRecurringNextStart(id int, next time.Time) error
+	SetRecurringPaused(id int, paused bool, next time.Time) error
+	DeleteRecurringAuction(id int) error
+	PutJob(j *Job) error
+	GetDueJobs(now time.Time, limit int) ([]Job, error)
+	GetJobs(limit int) ([]Job, error)
+	DeleteJob(id int) error
+	SetJobAttempt(j *Job) error
+}
+
 type DB struct {
 	*sqlx.DB
 }
 
 func NewDB(config *DatabaseConfig) (*DB, error) {
 	if config == nil {
-		errors.New("config should not be nil in NewDB()")
+		return nil, errors.New("config should not be nil in NewDB()")
 	}
 	db, err := sqlx.Open(config.Driver, config.Source)
 	if err != nil {
@@ -35,7 +106,6 @@ func (db *DB) GetUser(id int) *User {
 
 	if err != nil {
 		panic(err)
-		return nil
 	}
 
 	user.exists = true
@@ -52,7 +122,6 @@ func (db *DB) GetUserByName(name string) *User {
 
 	if err != nil {
 		panic(err)
-		return nil
 	}
 
 	user.exists = true
@@ -79,7 +148,6 @@ func (db *DB) GetUserByNameOrId(identifier string) *User {
 
 	if err != nil {
 		panic(err)
-		return nil
 	}
 
 	user.exists = true
@@ -100,8 +168,32 @@ func (db *DB) GetUsers(banned bool) ([]User, error) {
 func (db *DB) GetAdmins() ([]User, error) {
 	var users []User
 
-	err := db.Select(&users, db.Rebind("select * from botuser where admin=true"))
+	err := db.Select(&users, db.Rebind("select * from botuser where role in (?, ?)"), roleOwner, roleAdmin)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// Returns every tracked user, banned or not, in or out of the group.
+func (db *DB) GetAllUsers() ([]User, error) {
+	var users []User
+
+	err := db.Select(&users, db.Rebind("select * from botuser order by id"))
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// Returns the users who have any other role than bidder.
+func (db *DB) GetUsersWithRoles() ([]User, error) {
+	var users []User
 
+	err := db.Select(&users, db.Rebind("select * from botuser where role <> ? order by role, username"), roleBidder)
 	if err != nil {
 		return nil, err
 	}
@@ -120,22 +212,138 @@ func (db *DB) GetUserCount(banned bool) (int, error) {
 	return count, nil
 }
 
+// Returns the auction that has started and not ended yet. It may be past its
+// end time if the countdown is still running.
 func (db *DB) GetCurrentAuction() *Auction {
 	var auction Auction
 
-	err := db.Get(&auction, db.Rebind("select * from auction where ended=false and end_time>now()"))
+	err := db.Get(&auction, db.Rebind(`
+		select * from auction
+		where ended=false and (start_time is null or start_time <= now())
+		order by end_time limit 1`))
 	if err == sql.ErrNoRows {
 		return nil
 	}
 
 	if err != nil {
 		panic(err)
+	}
+
+	return &auction
+}
+
+func (db *DB) GetAuction(id int) *Auction {
+	var auction Auction
+
+	err := db.Get(&auction, db.Rebind("select * from auction where id=?"), id)
+	if err == sql.ErrNoRows {
 		return nil
 	}
 
+	if err != nil {
+		panic(err)
+	}
+
 	return &auction
 }
 
+func (db *DB) GetOpenAuctions() ([]Auction, error) {
+	var auctions []Auction
+
+	err := db.Select(&auctions, db.Rebind("select * from auction where ended=false order by end_time"))
+	if err != nil {
+		return nil, err
+	}
+
+	return auctions, nil
+}
+
+// Remembers how far the engine got with the auction, so that it can go on
+// after a restart.
+func (db *DB) SetAuctionPhase(id int, phase string, countdown int64) error {
+	_, err := db.Exec(db.Rebind(`
+		update auction set phase = ?, countdown = ? where id = ?`),
+		phase, countdown, id,
+	)
+
+	return err
+}
+
+// Stores the countdown position, unless the auction got paused, cancelled
+// or extended in the meantime.
+func (db *DB) SetAuctionCountdown(id int, countdown int64) error {
+	_, err := db.Exec(db.Rebind(`
+		update auction set phase = ?, countdown = ?
+		where id = ? and phase = ?`),
+		auctionCountdown, countdown, id, auctionCountdown,
+	)
+
+	return err
+}
+
+// Freezes the auction with the given time left. The countdown position is
+// kept for resuming.
+func (db *DB) PauseAuction(id int, remaining time.Duration) error {
+	_, err := db.Exec(db.Rebind(`
+		update auction set phase = ?, remaining = ? where id = ?`),
+		auctionPaused, int64(remaining), id,
+	)
+
+	return err
+}
+
+func (db *DB) SetAuctionEnd(id int, end time.Time, phase string, countdown int64) error {
+	_, err := db.Exec(db.Rebind(`
+		update auction set end_time = ?, phase = ?, countdown = ?, remaining = null where id = ?`),
+		end, phase, countdown, id,
+	)
+
+	return err
+}
+
+// Closes the auction without a winner. The bids stay on record.
+func (db *DB) CancelAuction(id int, reason string) error {
+	_, err := db.Exec(db.Rebind(`
+		update auction set ended = true, phase = ?, countdown = 0, bidder_id = 0, cancel_reason = ?
+		where id = ?`),
+		auctionCancelled, reason, id,
+	)
+
+	return err
+}
+
+// Returns the ids of everybody who bid in the auction.
+func (db *DB) GetAuctionBidders(id int) ([]int, error) {
+	var ids []int
+
+	err := db.Select(&ids, db.Rebind("select distinct user_id from bid where auction_id=?"), id)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (db *DB) SetAuctionMessage(id int, messageID int) error {
+	_, err := db.Exec(db.Rebind(`
+		update auction set bid_msg_id = ? where id = ?`),
+		messageID, id,
+	)
+
+	return err
+}
+
+// Remembers the live countdown message and the message pinned before it, so
+// that they can be put back after a restart.
+func (db *DB) SetAuctionCountdownMessage(id int, messageID, previousPin int) error {
+	_, err := db.Exec(db.Rebind(`
+		update auction set countdown_msg_id = ?, previous_pin_msg_id = ? where id = ?`),
+		messageID, previousPin, id,
+	)
+
+	return err
+}
+
 func (db *DB) PutAuction(end time.Time) error {
 	_, err := db.Exec(db.Rebind(`
 		insert into auction (
@@ -147,38 +355,505 @@ func (db *DB) PutAuction(end time.Time) error {
 	return err
 }
 
-func (db *DB) SetAuctionBid(id int, bid *Bid) error {
+// Creates an auction that starts later, of a recurring auction.
+func (db *DB) PutScheduledAuction(a *Auction) error {
+	_, err := db.Exec(db.Rebind(`
+		insert into auction (
+			start_time, end_time, bid_val, bid_type, reminder_plan, recurring_id, lot
+		) values (?, ?, ?, ?, ?, ?, ?)`),
+		a.StartTime, a.EndTime, a.BidVal, a.BidType, a.ReminderPlan, a.RecurringID, a.Lot,
+	)
+
+	return err
+}
+
+// Deletes the auctions of the recurring auction that have not started yet,
+// returning their start times, earliest first.
+func (db *DB) DeleteUpcomingAuctions(recurringID int) ([]time.Time, error) {
+	var starts []time.Time
+
+	err := db.Select(&starts, db.Rebind(`
+		delete from auction
+		where recurring_id = ? and ended = false and start_time > now()
+		returning start_time`),
+		recurringID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	return starts, nil
+}
+
+func (db *DB) SetAuctionReminderPlan(id int, plan string) error {
+	_, err := db.Exec(db.Rebind(`
+		update auction set reminder_plan = ? where id = ?`),
+		plan, id,
+	)
+
+	return err
+}
+
+func (db *DB) SetAuctionBid(id int, bid *Bid, bidderID int) error {
+	_, err := db.Exec(db.Rebind(`
+		update auction set bid_val= ?, bid_type = ?, bidder_id = ? where id = ?`),
+		bid.Value, bid.CoinType, bidderID, id,
+	)
+
+	return err
+}
+
+func (db *DB) PutBid(auctionID, userID int, bid *Bid) error {
+	_, err := db.Exec(db.Rebind(`
+		insert into bid (
+			auction_id, user_id, value, coin_type
+		) values (?, ?, ?, ?)`),
+		auctionID, userID, bid.Value, bid.CoinType,
+	)
+
+	return err
+}
+
+// Returns the latest bid of the user in every auction that has not ended.
+func (db *DB) GetUserActiveBids(userID int) ([]PlacedBid, error) {
+	var bids []PlacedBid
+
+	err := db.Select(&bids, db.Rebind(`
+		select distinct on (bid.auction_id) bid.* from bid
+		join auction on auction.id=bid.auction_id
+		where bid.user_id=? and auction.ended=false
+		order by bid.auction_id, bid.created_at desc`),
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return bids, nil
+}
+
+// Returns the ended auctions the user has won, most recent first.
+func (db *DB) GetWonAuctions(userID int) ([]Auction, error) {
+	var auctions []Auction
+
+	err := db.Select(&auctions, db.Rebind(`
+		select * from auction
+		where ended=true and bidder_id=?
+		order by end_time desc, id desc`),
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return auctions, nil
+}
+
+func (db *DB) GetUserBidStats(userID int) (*UserBidStats, error) {
+	var stats UserBidStats
+
+	err := db.Get(&stats, db.Rebind(`
+		select
+			count(*) as bids,
+			count(distinct auction_id) as auctions,
+			min(created_at) as first_bid
+		from bid where user_id=?`),
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+// Ranks users by a leaderboard metric over the auctions since the given time.
+// Amounts in BTC are converted to SKY with the conversion factor.
+func (db *DB) GetLeaderboard(metric string, since time.Time, conversionFactor int64, limit int) ([]LeaderboardEntry, error) {
+	var query string
+	var args []interface{}
+	switch metric {
+	case leaderboardWon:
+		query = `
+			select bidder_id as user_id, count(*) as score from auction
+			where ended=true and bidder_id<>0 and end_time>=?
+			group by bidder_id order by score desc limit ?`
+		args = []interface{}{since, limit}
+	case leaderboardSpent:
+		query = `
+			select bidder_id as user_id,
+				sum(case when bid_type='BTC' then bid_val*? else bid_val end) as score
+			from auction
+			where ended=true and bidder_id<>0 and end_time>=?
+			group by bidder_id order by score desc limit ?`
+		args = []interface{}{conversionFactor, since, limit}
+	case leaderboardBids:
+		query = `
+			select user_id, count(*) as score from bid
+			where created_at>=?
+			group by user_id order by score desc limit ?`
+		args = []interface{}{since, limit}
+	default:
+		return nil, fmt.Errorf("unknown leaderboard metric: %s", metric)
+	}
+
+	var entries []LeaderboardEntry
+	if err := db.Select(&entries, db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (db *DB) GetAuctionStats(conversionFactor int64) (*AuctionStats, error) {
+	var stats AuctionStats
+
+	err := db.Get(&stats, db.Rebind(`
+		with per_auction as (
+			select
+				count(bid.id) as bids,
+				count(distinct bid.user_id) as bidders,
+				(array_agg(case when bid.coin_type='BTC' then bid.value*? else bid.value end
+					order by bid.created_at))[1] as opening,
+				min(case when auction.bid_type='BTC' then auction.bid_val*? else auction.bid_val end) as final
+			from auction join bid on bid.auction_id=auction.id
+			where auction.ended=true
+			group by auction.id
+		)
+		select
+			count(*) as auctions,
+			coalesce(avg(bids), 0) as avg_bids,
+			coalesce(avg(bidders), 0) as avg_bidders,
+			coalesce(avg(final/nullif(opening, 0)), 0) as avg_price_ratio,
+			coalesce(sum(final), 0) as total_sold
+		from per_auction`),
+		conversionFactor, conversionFactor,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+const auctionResultColumns = `auction.*,
+	(select count(*) from bid where bid.auction_id=auction.id) as bid_count,
+	(select count(distinct user_id) from bid where bid.auction_id=auction.id) as bidder_count`
+
+// Returns ended auctions, most recent first.
+func (db *DB) GetEndedAuctions(limit, offset int) ([]AuctionResult, error) {
+	var results []AuctionResult
+
+	err := db.Select(&results, db.Rebind(`
+		select `+auctionResultColumns+` from auction
+		where ended=true
+		order by end_time desc, id desc
+		limit ? offset ?`),
+		limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (db *DB) GetEndedAuctionCount() (int, error) {
+	var count int
+
+	err := db.Get(&count, db.Rebind("select count(*) from auction where ended=true"))
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (db *DB) GetAuctionResult(id int) *AuctionResult {
+	var result AuctionResult
+
+	err := db.Get(&result, db.Rebind("select "+auctionResultColumns+" from auction where id=?"), id)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+
+	if err != nil {
+		panic(err)
+	}
+
+	return &result
+}
+
+// Returns the ended auctions that have a winner who has not paid yet.
+func (db *DB) GetUnsettledAuctions() ([]Auction, error) {
+	var auctions []Auction
+
+	err := db.Select(&auctions, db.Rebind(`
+		select * from auction
+		where ended=true and bidder_id<>0 and payment_status<>?
+		order by id`),
+		paymentPaid,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return auctions, nil
+}
+
+func (db *DB) SetAuctionPayment(id int, status string, confirmations int, txid string) error {
+	_, err := db.Exec(db.Rebind(`
+		update auction set payment_status = ?, payment_confirmations = ?, payment_txid = ?
+		where id = ?`),
+		status, confirmations, txid, id,
+	)
+
+	return err
+}
+
+// Returns the transactions that pay for some auction already.
+func (db *DB) GetClaimedPayments() ([]string, error) {
+	var txids []string
+
+	err := db.Select(&txids, db.Rebind("select payment_txid from auction where payment_txid<>''"))
+	if err != nil {
+		return nil, err
+	}
+
+	return txids, nil
+}
+
+func (db *DB) EndAuction(id int) error {
+	_, err := db.Exec(db.Rebind(`
+		update auction set ended=true, phase=?, countdown=0 where id=?`),
+		auctionEnded, id,
+	)
+
+	return err
+}
+
+func (db *DB) PutCaptcha(c *Captcha) error {
+	_, err := db.Exec(db.Rebind(`
+		insert into captcha (
+			user_id, message_id, answer, expires_at
+		) values (?, ?, ?, ?)
+		on conflict (user_id) do update set
+			message_id = excluded.message_id,
+			answer = excluded.answer,
+			expires_at = excluded.expires_at`),
+		c.UserID, c.MessageID, c.Answer, c.ExpiresAt,
+	)
+
+	return err
+}
+
+func (db *DB) GetCaptcha(userID int) *Captcha {
+	var captcha Captcha
+
+	err := db.Get(&captcha, db.Rebind("select * from captcha where user_id=?"), userID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+
+	if err != nil {
+		panic(err)
+	}
+
+	return &captcha
+}
+
+func (db *DB) GetExpiredCaptchas() ([]Captcha, error) {
+	var captchas []Captcha
+
+	err := db.Select(&captchas, db.Rebind("select * from captcha where expires_at<=now()"))
+	if err != nil {
+		return nil, err
+	}
+
+	return captchas, nil
+}
+
+func (db *DB) DeleteCaptcha(userID int) error {
+	_, err := db.Exec(db.Rebind("delete from captcha where user_id=?"), userID)
+	return err
+}
+
+func (db *DB) PutMembershipEvent(e *MembershipEvent) error {
+	_, err := db.Exec(db.Rebind(`
+		insert into membership_event (user_id, event, actor_id) values (?, ?, ?)`),
+		e.UserID, e.Event, e.ActorID,
+	)
+
+	return err
+}
+
+// Returns the latest joins and leaves of the user.
+func (db *DB) GetMembershipEvents(userID int, limit int) ([]MembershipEvent, error) {
+	var events []MembershipEvent
+
+	err := db.Select(&events, db.Rebind(`
+		select * from membership_event
+		where user_id=?
+		order by created_at desc, id desc
+		limit ?`),
+		userID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (db *DB) PutUsernameChange(userID int, username string) error {
 	_, err := db.Exec(db.Rebind(`
-		update auction set bid_val= ?, bid_type = ? where id = ?`),
-		bid.Value, bid.CoinType, id,
+		insert into username_history (user_id, username) values (?, ?)`),
+		userID, username,
 	)
 
 	return err
 }
 
-func (db *DB) EndAuction() error {
+// Returns the previous usernames of the user, latest first.
+func (db *DB) GetUsernameHistory(userID int) ([]UsernameChange, error) {
+	var changes []UsernameChange
+
+	err := db.Select(&changes, db.Rebind(`
+		select * from username_history
+		where user_id=?
+		order by changed_at desc, id desc`),
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+func (db *DB) PutAuditEntry(e *AuditEntry) error {
 	_, err := db.Exec(db.Rebind(`
-		update auction set ended=true where ended=false`),
+		insert into audit_log (
+			actor_id, target_id, action, reason, message
+		) values (?, ?, ?, ?, ?)`),
+		e.ActorID, e.TargetID, e.Action, e.Reason, e.Message,
 	)
 
 	return err
 }
 
+// Returns the latest audit log entries, about the given user only unless the
+// id is zero.
+func (db *DB) GetAuditLog(targetID int, limit int) ([]AuditEntry, error) {
+	var entries []AuditEntry
+
+	err := db.Select(&entries, db.Rebind(`
+		select * from audit_log
+		where ?=0 or target_id=?
+		order by created_at desc, id desc
+		limit ?`),
+		targetID, targetID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (db *DB) PutStrike(s *Strike) error {
+	_, err := db.Exec(db.Rebind(`
+		insert into strike (
+			user_id, kind, reason, auction_id
+		) values (?, ?, ?, ?)`),
+		s.UserID, s.Kind, s.Reason, s.AuctionID,
+	)
+
+	return err
+}
+
+func (db *DB) GetStrikes(userID int) ([]Strike, error) {
+	var strikes []Strike
+
+	err := db.Select(&strikes, db.Rebind("select * from strike where user_id=? order by created_at"), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return strikes, nil
+}
+
+func (db *DB) GetActiveStrikeCount(userID int) int {
+	var count int
+
+	err := db.Get(&count, db.Rebind("select count(*) from strike where user_id=? and pardoned=false"), userID)
+	if err != nil {
+		panic(err)
+	}
+
+	return count
+}
+
+func (db *DB) HasAuctionStrike(auctionID int) bool {
+	var count int
+
+	err := db.Get(&count, db.Rebind("select count(*) from strike where auction_id=?"), auctionID)
+	if err != nil {
+		panic(err)
+	}
+
+	return count > 0
+}
+
+// Pardons all active strikes of the user and returns how many there were.
+func (db *DB) PardonStrikes(userID int) (int, error) {
+	res, err := db.Exec(db.Rebind(`
+		update strike set pardoned=true where user_id=? and pardoned=false`),
+		userID,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
 func (db *DB) PutUser(u *User) error {
+	if u.Role == "" {
+		u.Role = roleBidder
+	}
 	if u.exists {
 		_, err := db.Exec(db.Rebind(`
 			update botuser
 				set username = ?,
 				first_name = ?,
 				last_name = ?,
+				enlisted = ?,
 				banned = ?,
-				admin = ?
+				banned_by_strikes = ?,
+				role = ?,
+				role_synced = ?,
+				role_manual = ?,
+				suspended_until = ?,
+				language = ?,
+				timezone = ?,
+				joined_at = ?
 			where id = ?`),
 			u.UserName,
 			u.FirstName,
 			u.LastName,
+			u.Enlisted,
 			u.Banned,
-			u.Admin,
+			u.BannedByStrikes,
+			u.Role,
+			u.RoleSynced,
+			u.RoleManual,
+			u.SuspendedUntil,
+			u.Language,
+			u.Timezone,
+			u.JoinedAt,
 			u.ID,
 		)
 		return err
@@ -186,14 +861,21 @@ func (db *DB) PutUser(u *User) error {
 		_, err := db.Exec(db.Rebind(`
 			insert into botuser (
 				id, username, first_name, last_name,
-				banned, admin
-			) values (?, ?, ?, ?, ?, ?)`),
+				enlisted, banned, banned_by_strikes, role, role_synced, role_manual, language, timezone, joined_at
+			) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
 			u.ID,
 			u.UserName,
 			u.FirstName,
 			u.LastName,
+			u.Enlisted,
 			u.Banned,
-			u.Admin,
+			u.BannedByStrikes,
+			u.Role,
+			u.RoleSynced,
+			u.RoleManual,
+			u.Language,
+			u.Timezone,
+			u.JoinedAt,
 		)
 		if err == nil {
 			u.exists = true
@@ -201,3 +883,126 @@ func (db *DB) PutUser(u *User) error {
 		return err
 	}
 }
+
+func (db *DB) PutRecurringAuction(r *RecurringAuction) error {
+	return db.QueryRowx(db.Rebind(`
+		insert into recurring_auction (
+			schedule, duration, reminder_plan, start_bid_val, start_bid_type, lot, next_start, created_by
+		) values (?, ?, ?, ?, ?, ?, ?, ?)
+		returning id`),
+		r.Schedule, r.Duration, r.ReminderPlan, r.StartBidVal, r.StartBidType, r.Lot, r.NextStart, r.CreatedBy,
+	).Scan(&r.ID)
+}
+
+func (db *DB) GetRecurringAuction(id int) *RecurringAuction {
+	var r RecurringAuction
+
+	err := db.Get(&r, db.Rebind("select * from recurring_auction where id=?"), id)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+
+	if err != nil {
+		panic(err)
+	}
+
+	return &r
+}
+
+func (db *DB) GetRecurringAuctions() ([]RecurringAuction, error) {
+	var recurring []RecurringAuction
+
+	err := db.Select(&recurring, "select * from recurring_auction order by id")
+	if err != nil {
+		return nil, err
+	}
+
+	return recurring, nil
+}
+
+// Remembers when the next auction of the recurring auction starts, the
+// earlier ones have been created.
+func (db *DB) SetRecurringNextStart(id int, next time.Time) error {
+	_, err := db.Exec(db.Rebind(`
+		update recurring_auction set next_start = ? where id = ?`),
+		next, id,
+	)
+
+	return err
+}
+
+func (db *DB) SetRecurringPaused(id int, paused bool, next time.Time) error {
+	_, err := db.Exec(db.Rebind(`
+		update recurring_auction set paused = ?, next_start = ? where id = ?`),
+		paused, next, id,
+	)
+
+	return err
+}
+
+func (db *DB) DeleteRecurringAuction(id int) error {
+	_, err := db.Exec(db.Rebind("delete from recurring_auction where id = ?"), id)
+
+	return err
+}
+
+func (db *DB) PutJob(j *Job) error {
+	return db.QueryRowx(db.Rebind(`
+		insert into job (
+			kind, payload, run_at
+		) values (?, ?, ?)
+		returning id`),
+		j.Kind, j.Payload, j.RunAt,
+	).Scan(&j.ID)
+}
+
+// Returns the jobs that are due at the given time, the longest due first.
+func (db *DB) GetDueJobs(now time.Time, limit int) ([]Job, error) {
+	var jobs []Job
+
+	err := db.Select(&jobs, db.Rebind(`
+		select * from job
+		where failed=false and run_at <= ?
+		order by run_at, id limit ?`),
+		now, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// Returns the jobs that are still to do, then those that failed.
+func (db *DB) GetJobs(limit int) ([]Job, error) {
+	var jobs []Job
+
+	err := db.Select(&jobs, db.Rebind(`
+		select * from job
+		order by failed, run_at, id limit ?`),
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func (db *DB) DeleteJob(id int) error {
+	_, err := db.Exec(db.Rebind("delete from job where id = ?"), id)
+
+	return err
+}
+
+// Records a failed attempt of the job and when to try again, or that it
+// failed for good.
+func (db *DB) SetJobAttempt(j *Job) error {
+	_, err := db.Exec(db.Rebind(`
+		update job set attempts = ?, last_error = ?, run_at = ?, failed = ?
+		where id = ?`),
+		j.Attempts, j.LastError, j.RunAt, j.Failed, j.ID,
+	)
+
+	return err
+}
@@ -0,0 +1,129 @@
+package auction_butler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+// Parses end times relative to now, "in [duration]" or "+[duration]". The
+// duration may be split into words, like "2h 30m", and be followed by the
+// auction info. Tells whether the words are relative at all.
+func parseRelativeTime(words []string) (time.Duration, bool, error) {
+	var rest []string
+	switch {
+	case strings.ToLower(words[0]) == "in" && len(words) > 1:
+		rest = words[1:]
+	case strings.HasPrefix(words[0], "+"):
+		rest = append([]string{strings.TrimPrefix(words[0], "+")}, words[1:]...)
+	default:
+		return 0, false, nil
+	}
+
+	// as many words as still make a duration
+	var s string
+	var d time.Duration
+	for _, word := range rest {
+		if word == "" {
+			continue
+		}
+		parsed, err := parseDuration(s + word)
+		if err != nil {
+			break
+		}
+		s, d = s+word, parsed
+	}
+	if d <= 0 {
+		return 0, true, fmt.Errorf("invalid duration: %s", strings.Join(rest, " "))
+	}
+	return d, true, nil
+}
+
+// Tells whether the word looks like an IANA zone name, like Europe/Berlin,
+// rather than a date like 2018/06/24.
+func isZoneName(word string) bool {
+	return strings.Contains(word, "/") && unicode.IsLetter([]rune(word)[0])
+}
+
+// Finds a time zone among the words, an IANA name like Europe/Berlin or an
+// abbreviation like CET, and returns it with the other words.
+func extractZone(words []string) (*time.Location, []string, error) {
+	for i, word := range words {
+		if _, found := zoneAbbreviations[strings.ToUpper(word)]; !found && !isZoneName(word) {
+			continue
+		}
+		zone, err := loadZone(word)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		rest := append(append([]string(nil), words[:i]...), words[i+1:]...)
+		return zone, rest, nil
+	}
+	return nil, words, nil
+}
+
+// Shows the parsed end time in the zone it was given in and in UTC, with
+// buttons to create the auction or not. Relative end times are shown in the
+// zone of the user.
+func (bot *Bot) askEndTimeConfirmation(ctx *Context, end time.Time) error {
+	if end.Location() == time.UTC {
+		end = end.In(bot.zone(ctx.User))
+	}
+	text := bot.text(ctx.User, "confirm_end_time", endTimeMessage{bot.bothTimes(end)})
+
+	data := fmt.Sprintf("setauction:%d:", ctx.User.ID)
+	msg := tgbotapi.NewMessage(ctx.message.Chat.ID, text)
+	msg.ParseMode = "HTML"
+	msg.ReplyToMessageID = ctx.message.MessageID
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(bot.text(ctx.User, "confirm", nil), data+strconv.FormatInt(end.Unix(), 10)),
+		tgbotapi.NewInlineKeyboardButtonData(bot.text(ctx.User, "cancel", nil), data+"cancel"),
+	))
+	_, err := bot.telegram.Send(msg)
+	return err
+}
+
+// Handles the buttons of the end time confirmation, the arguments are
+// "user:unix time" or "user:cancel".
+func (bot *Bot) handleCallbackSetAuction(ctx *Context, query *tgbotapi.CallbackQuery, args string) error {
+	parts := strings.Split(args, ":")
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid confirmation: %s", args)
+	}
+	userID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return fmt.Errorf("invalid confirmation: %s", args)
+	}
+	if query.From.ID != userID || !ctx.User.Can(permAuction) {
+		return fmt.Errorf("this confirmation is for someone else")
+	}
+
+	text := bot.text(ctx.User, "not_created", nil)
+	if parts[1] != "cancel" {
+		unix, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid confirmation: %s", args)
+		}
+		end := time.Unix(unix, 0).UTC()
+		if !end.After(bot.clock.Now()) {
+			return fmt.Errorf("%s is in the past already", niceTime(end))
+		}
+
+		if err := bot.db.PutAuction(end); err != nil {
+			return fmt.Errorf("failed to create auction: %v", err)
+		}
+		bot.Reschedule()
+		log.Printf("auction ending %s created by %s", end, ctx.User.NameAndTags())
+		text = bot.text(ctx.User, "auction_created", endTimeMessage{bot.userTime(ctx.User, end)})
+	}
+
+	edit := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, text)
+	edit.ParseMode = "HTML"
+	_, err = bot.telegram.Send(edit)
+	return err
+}
@@ -0,0 +1,43 @@
+package auction_butler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseEndTime(t *testing.T) {
+	cases := []struct {
+		args string
+		want time.Time
+	}{
+		{"in 2h30m", testStart.Add(2*time.Hour + 30*time.Minute)},
+		{"in 2h 30m", testStart.Add(2*time.Hour + 30*time.Minute)},
+		{"+90m", testStart.Add(90 * time.Minute)},
+		{"+ 3", testStart.Add(3 * time.Hour)},
+		// summer time in Berlin is two hours ahead
+		{"18:00 Europe/Berlin", time.Date(2018, 5, 1, 16, 0, 0, 0, time.UTC)},
+		{"18:00 CET", time.Date(2018, 5, 1, 17, 0, 0, 0, time.UTC)},
+		{"pst 18:00", time.Date(2018, 5, 2, 2, 0, 0, 0, time.UTC)},
+		// dates are not zones
+		{"2018/06/24 18:00", time.Date(2018, 6, 24, 18, 0, 0, 0, time.UTC)},
+		// the auction info follows the end time
+		{"in 2h a fluffy kitty", testStart.Add(2 * time.Hour)},
+		{"+1h 30m kitty #5", testStart.Add(90 * time.Minute)},
+	}
+	for _, c := range cases {
+		got, err := parseStartAuctioArgs(c.args, testStart, time.UTC)
+		if err != nil {
+			t.Errorf("failed to parse %q: %v", c.args, err)
+			continue
+		}
+		if !got.Equal(c.want) {
+			t.Errorf("%q: got %v, want %v", c.args, got.UTC(), c.want)
+		}
+	}
+
+	for _, args := range []string{"in soon", "+0m", "18:00 Mars/Olympus"} {
+		if _, err := parseStartAuctioArgs(args, testStart, time.UTC); err == nil {
+			t.Errorf("expected an error for %q", args)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package auction_butler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+// Kinds of jobs, the payload of each is the json of the struct below it
+const (
+	jobDeleteMessage = "delete_message"
+	jobReminder      = "reminder"
+	jobYell          = "yell"
+	jobWhisper       = "whisper"
+)
+
+const (
+	jobCheckInterval = 5 * time.Second
+	jobBatchSize     = 20
+	maxJobAttempts   = 5
+	// doubled after every failed attempt
+	jobRetryDelay = 30 * time.Second
+	jobListLimit  = 50
+)
+
+type deleteMessageJob struct {
+	ChatID    int64 `json:"chat_id"`
+	MessageID int   `json:"message_id"`
+}
+
+// A reminder of the end of an auction, in html. It is dropped if the auction
+// is over by the time it runs.
+type reminderJob struct {
+	AuctionID int    `json:"auction_id"`
+	Text      string `json:"text"`
+}
+
+// A message to the group, in html
+type yellJob struct {
+	Text string `json:"text"`
+}
+
+// A private message to a user, in html
+type whisperJob struct {
+	UserID int    `json:"user_id"`
+	Text   string `json:"text"`
+}
+
+// Stores a job to run at the given time. The job survives restarts of the
+// bot, unlike a timer.
+func (bot *Bot) enqueue(kind string, payload interface{}, at time.Time) error {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	job := &Job{Kind: kind, Payload: string(encoded), RunAt: at}
+	if err := bot.db.PutJob(job); err != nil {
+		return fmt.Errorf("failed to save %s job: %v", kind, err)
+	}
+
+	// wake the worker if the job is due before it looks again
+	select {
+	case bot.jobsChan <- job.ID:
+	default:
+	}
+	return nil
+}
+
+// Runs the job right away and leaves it to the worker to retry if that
+// fails, so the caller does not have to wait for telegram to come back.
+func (bot *Bot) runOrRetry(kind string, payload interface{}) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("failed to encode %s job: %v", kind, err)
+		return
+	}
+	job := &Job{Kind: kind, Payload: string(encoded)}
+	if err = bot.runJob(job); err == nil {
+		return
+	}
+
+	log.Printf("%s failed, will retry: %v", kind, err)
+	if err := bot.enqueue(kind, payload, bot.clock.Now().Add(jobRetryDelay)); err != nil {
+		log.Print(err)
+	}
+}
+
+func (bot *Bot) runJob(job *Job) error {
+	switch job.Kind {
+	case jobDeleteMessage:
+		var p deleteMessageJob
+		if err := json.Unmarshal([]byte(job.Payload), &p); err != nil {
+			return err
+		}
+		_, err := bot.telegram.DeleteMessage(tgbotapi.DeleteMessageConfig{
+			ChatID:    p.ChatID,
+			MessageID: p.MessageID,
+		})
+		// someone was faster, which is just as good
+		if err != nil && strings.Contains(err.Error(), "message to delete not found") {
+			return nil
+		}
+		return err
+	case jobReminder:
+		var p reminderJob
+		if err := json.Unmarshal([]byte(job.Payload), &p); err != nil {
+			return err
+		}
+		if auction := bot.db.GetAuction(p.AuctionID); auction == nil || auction.Ended {
+			return nil
+		}
+		_, err := bot.Send(&Context{}, "yell", "html", p.Text)
+		return err
+	case jobYell:
+		var p yellJob
+		if err := json.Unmarshal([]byte(job.Payload), &p); err != nil {
+			return err
+		}
+		_, err := bot.Send(&Context{}, "yell", "html", p.Text)
+		return err
+	case jobWhisper:
+		var p whisperJob
+		if err := json.Unmarshal([]byte(job.Payload), &p); err != nil {
+			return err
+		}
+		_, err := bot.Whisper(p.UserID, "html", p.Text)
+		return err
+	default:
+		return fmt.Errorf("unknown job kind: %s", job.Kind)
+	}
+}
+
+// Runs the jobs that are due. Done jobs are deleted, failed ones are tried
+// again later, until they have failed too often.
+func (bot *Bot) runDueJobs(now time.Time) error {
+	jobs, err := bot.db.GetDueJobs(now, jobBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for i := range jobs {
+		job := &jobs[i]
+		err := bot.runJob(job)
+		if err == nil {
+			if err := bot.db.DeleteJob(job.ID); err != nil {
+				log.Printf("failed to delete job #%d: %v", job.ID, err)
+			}
+			continue
+		}
+
+		job.Attempts++
+		job.LastError = err.Error()
+		job.RunAt = now.Add(jobRetryDelay << uint(job.Attempts-1))
+		job.Failed = job.Attempts >= maxJobAttempts
+		if job.Failed {
+			log.Printf("job #%d (%s) failed for good: %v", job.ID, job.Kind, err)
+		} else {
+			log.Printf("job #%d (%s) failed, attempt %d: %v", job.ID, job.Kind, job.Attempts, err)
+		}
+		if err := bot.db.SetJobAttempt(job); err != nil {
+			log.Printf("failed to update job #%d: %v", job.ID, err)
+		}
+	}
+	return nil
+}
+
+// Runs due jobs, looking for them every few seconds and whenever a job is
+// added. Jobs that were due while the bot was down run on start.
+func (bot *Bot) watchJobs() {
+	timer := bot.clock.NewTimer(0)
+	for {
+		select {
+		case <-timer.C():
+		case <-bot.jobsChan:
+		}
+		if err := bot.runDueJobs(bot.clock.Now()); err != nil {
+			log.Printf("failed to run jobs: %v", err)
+		}
+		resetTimer(timer, jobCheckInterval)
+	}
+}
+
+func (bot *Bot) handleCommandJobs(ctx *Context, command, args string) error {
+	jobs, err := bot.db.GetJobs(jobListLimit)
+	if err != nil {
+		return fmt.Errorf("failed to get jobs: %v", err)
+	}
+
+	var msg jobsMessage
+	for _, job := range jobs {
+		msg.Jobs = append(msg.Jobs, jobLine{
+			ID:        job.ID,
+			Kind:      job.Kind,
+			RunAt:     bot.localTime(ctx.User, job.RunAt),
+			Failed:    job.Failed,
+			Attempts:  job.Attempts,
+			LastError: job.LastError,
+		})
+	}
+	return bot.replyTemplateLines(ctx, "jobs", msg)
+}
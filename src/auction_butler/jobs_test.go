@@ -0,0 +1,51 @@
+package auction_butler
+
+import (
+	"testing"
+	"time"
+)
+
+// Runs due jobs and retries one that fails until it gives up. Deleting a
+// message that is gone already and reminding of an auction that is over
+// count as done.
+func TestJobs(t *testing.T) {
+	bot, clock, telegram := newTestBot(t, testConfig())
+	now := clock.Now()
+	if err := bot.enqueue(jobDeleteMessage, deleteMessageJob{ChatID: -100, MessageID: 7}, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.enqueue(jobDeleteMessage, deleteMessageJob{ChatID: -100, MessageID: 7}, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.enqueue(jobReminder, reminderJob{AuctionID: 99, Text: "too late"}, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.enqueue(jobYell, yellJob{"later"}, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	broken := &Job{Kind: "unknown", RunAt: now}
+	if err := bot.db.PutJob(broken); err != nil {
+		t.Fatal(err)
+	}
+
+	for attempt := 1; attempt <= maxJobAttempts; attempt++ {
+		if err := bot.runDueJobs(now); err != nil {
+			t.Fatalf("failed to run jobs: %v", err)
+		}
+		now = now.Add(jobRetryDelay << uint(attempt-1))
+	}
+
+	jobs, err := bot.db.GetJobs(jobListLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 || jobs[0].Kind != jobYell || jobs[1].ID != broken.ID {
+		t.Fatalf("unexpected jobs left: %+v", jobs)
+	}
+	if !jobs[1].Failed || jobs[1].Attempts != maxJobAttempts || jobs[1].LastError == "" {
+		t.Errorf("broken job not given up: %+v", jobs[1])
+	}
+	if sent := telegram.Sent(); len(sent) != 0 {
+		t.Errorf("sent %q, want nothing", sent)
+	}
+}
@@ -0,0 +1,94 @@
+package auction_butler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Metrics members can be ranked by
+const (
+	leaderboardWon   = "won"
+	leaderboardSpent = "spent"
+	leaderboardBids  = "bids"
+)
+
+const leaderboardSize = 10
+
+var leaderboardWindows = map[string]time.Duration{
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"all":   0,
+}
+
+// Parses "[won|spent|bids] [week|month|all]" in any order.
+func parseLeaderboardArgs(args string) (metric, window string, err error) {
+	metric, window = leaderboardWon, "all"
+	for _, word := range strings.Fields(strings.ToLower(args)) {
+		switch word {
+		case leaderboardWon, leaderboardSpent, leaderboardBids:
+			metric = word
+		default:
+			if _, found := leaderboardWindows[word]; !found {
+				err = fmt.Errorf("unknown leaderboard option: %s", word)
+				return
+			}
+			window = word
+		}
+	}
+	return
+}
+
+func (bot *Bot) handleCommandLeaderboard(ctx *Context, command, args string) error {
+	metric, window, err := parseLeaderboardArgs(args)
+	if err != nil {
+		return err
+	}
+
+	var since time.Time
+	if d := leaderboardWindows[window]; d > 0 {
+		since = bot.clock.Now().Add(-d)
+	}
+
+	entries, err := bot.db.GetLeaderboard(metric, since, bot.config.ConversionFactor, leaderboardSize)
+	if err != nil {
+		return fmt.Errorf("failed to get leaderboard: %v", err)
+	}
+
+	msg := leaderboardMessage{Metric: metric, Window: window}
+	for i, entry := range entries {
+		line := leaderboardLine{Rank: i + 1, Name: fmt.Sprintf("%d", entry.UserID)}
+		if user := bot.db.GetUser(entry.UserID); user != nil {
+			line.Name = user.Name()
+		}
+		switch metric {
+		case leaderboardSpent:
+			line.Score = fmt.Sprintf("%.0f SKY", entry.Score)
+		default:
+			line.Score = fmt.Sprintf("%.0f", entry.Score)
+		}
+		msg.Entries = append(msg.Entries, line)
+	}
+
+	return bot.ReplyTemplate(ctx, "leaderboard", msg)
+}
+
+func (bot *Bot) handleCommandStats(ctx *Context, command, args string) error {
+	total, err := bot.db.GetEndedAuctionCount()
+	if err != nil {
+		return fmt.Errorf("failed to count auctions: %v", err)
+	}
+	stats, err := bot.db.GetAuctionStats(bot.config.ConversionFactor)
+	if err != nil {
+		return fmt.Errorf("failed to get auction statistics: %v", err)
+	}
+
+	return bot.ReplyTemplate(ctx, "stats", statsMessage{
+		Total:         total,
+		Auctions:      stats.Auctions,
+		AvgBids:       stats.AvgBids,
+		AvgBidders:    stats.AvgBidders,
+		AvgPriceRatio: stats.AvgPriceRatio,
+		TotalSold:     stats.TotalSold,
+	})
+}
@@ -0,0 +1,99 @@
+package auction_butler
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+// Membership events
+const (
+	memberJoin  = "join"
+	memberLeave = "leave"
+	memberKick  = "kick"
+)
+
+// How many membership events /whois shows
+const membershipHistorySize = 5
+
+// Pause between telegram requests when checking all users, to stay below
+// the rate limit
+const reconcileDelay = 100 * time.Millisecond
+
+// Records that the user joined or left the group. The actor is who added or
+// removed them, zero if they did it themselves or nobody knows.
+func (bot *Bot) logMembership(userID int, event string, actorID int) {
+	if actorID == userID {
+		actorID = 0
+	}
+	e := MembershipEvent{UserID: userID, Event: event, ActorID: actorID}
+	if err := bot.db.PutMembershipEvent(&e); err != nil {
+		log.Printf("failed to record %s of user %d: %v", event, userID, err)
+	}
+}
+
+// Marks the user as being in the group, remembering the first time.
+func (u *User) join(now time.Time) {
+	u.Enlisted = true
+	if !u.JoinedAt.Valid {
+		u.JoinedAt = NullTime{Time: now, Valid: true}
+	}
+}
+
+// Asks telegram about every tracked user and fixes their enlisted status if
+// they joined or left while the bot was offline.
+func (bot *Bot) reconcileMembers() error {
+	users, err := bot.db.GetAllUsers()
+	if err != nil {
+		return fmt.Errorf("failed to get users: %v", err)
+	}
+
+	var changed int
+	for _, stored := range users {
+		// users who are still solving a captcha are not enlisted on purpose
+		if bot.db.GetCaptcha(stored.ID) != nil {
+			continue
+		}
+
+		member, err := bot.telegram.GetChatMember(tgbotapi.ChatConfigWithUser{
+			ChatID: bot.config.ChatID,
+			UserID: stored.ID,
+		})
+		time.Sleep(reconcileDelay)
+		if err != nil {
+			log.Printf("failed to get chat member %d: %v", stored.ID, err)
+			continue
+		}
+
+		inGroup := !member.HasLeft() && !member.WasKicked()
+		if inGroup == stored.Enlisted {
+			continue
+		}
+
+		user := bot.db.GetUser(stored.ID)
+		if user == nil {
+			continue
+		}
+		event := memberJoin
+		if inGroup {
+			user.join(bot.clock.Now())
+		} else {
+			user.Enlisted = false
+			event = memberLeave
+			if member.WasKicked() {
+				event = memberKick
+			}
+		}
+		if err := bot.db.PutUser(user); err != nil {
+			return fmt.Errorf("failed to save the user: %v", err)
+		}
+		bot.logMembership(user.ID, event, 0)
+		changed++
+
+		log.Printf("missed %s: %s", event, user.NameAndTags())
+	}
+
+	log.Printf("checked membership of %d users, %d changed", len(users), changed)
+	return nil
+}
@@ -0,0 +1,455 @@
+package auction_butler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// An in-memory Store for tests. It keeps what the engine, the captcha, the
+// payment checks, the strikes and the job queue use; the other methods are
+// those of the nil embedded Store and panic if called.
+type memStore struct {
+	Store
+
+	mu       sync.Mutex
+	clock    Clock
+	users    map[int]User
+	auctions []Auction
+	captchas map[int]Captcha
+	strikes  []Strike
+	audit    []AuditEntry
+	events   []MembershipEvent
+	jobs     []Job
+	lastID   int
+
+	recurring []RecurringAuction
+}
+
+func newMemStore(clock Clock) *memStore {
+	return &memStore{
+		clock:    clock,
+		users:    make(map[int]User),
+		captchas: make(map[int]Captcha),
+	}
+}
+
+func (m *memStore) nextID() int {
+	m.lastID++
+	return m.lastID
+}
+
+func (m *memStore) GetUser(id int) *User {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, found := m.users[id]
+	if !found {
+		return nil
+	}
+	u.exists = true
+	return &u
+}
+
+func (m *memStore) GetUserByNameOrId(identifier string) *User {
+	if id, err := strconv.Atoi(identifier); err == nil {
+		return m.GetUser(id)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.UserName == identifier {
+			u.exists = true
+			return &u
+		}
+	}
+	return nil
+}
+
+func (m *memStore) PutUser(u *User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if u.Role == "" {
+		u.Role = roleBidder
+	}
+	m.users[u.ID] = *u
+	return nil
+}
+
+func (m *memStore) GetAdmins() ([]User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var admins []User
+	for _, u := range m.users {
+		if u.Role == roleOwner || u.Role == roleAdmin {
+			admins = append(admins, u)
+		}
+	}
+	sort.Slice(admins, func(i, j int) bool { return admins[i].ID < admins[j].ID })
+	return admins, nil
+}
+
+func (m *memStore) auction(id int) *Auction {
+	for i := range m.auctions {
+		if m.auctions[i].ID == id {
+			return &m.auctions[i]
+		}
+	}
+	return nil
+}
+
+func (m *memStore) PutAuction(end time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.auctions = append(m.auctions, Auction{
+		ID:            m.nextID(),
+		EndTime:       NewNullTime(end),
+		PaymentStatus: paymentUnpaid,
+		Phase:         auctionOpen,
+	})
+	return nil
+}
+
+func (m *memStore) GetAuction(id int) *Auction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a := m.auction(id)
+	if a == nil {
+		return nil
+	}
+	copied := *a
+	return &copied
+}
+
+func (m *memStore) GetCurrentAuction() *Auction {
+	open, _ := m.GetOpenAuctions()
+	now := m.clock.Now()
+	for _, a := range open {
+		if !a.StartTime.Valid || !a.StartTime.Time.After(now) {
+			return &a
+		}
+	}
+	return nil
+}
+
+func (m *memStore) GetOpenAuctions() ([]Auction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var open []Auction
+	for _, a := range m.auctions {
+		if !a.Ended {
+			open = append(open, a)
+		}
+	}
+	sort.SliceStable(open, func(i, j int) bool { return open[i].EndTime.Time.Before(open[j].EndTime.Time) })
+	return open, nil
+}
+
+// Changes the auction with the given id, if there is one.
+func (m *memStore) update(id int, change func(a *Auction)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if a := m.auction(id); a != nil {
+		change(a)
+	}
+	return nil
+}
+
+func (m *memStore) SetAuctionPhase(id int, phase string, countdown int64) error {
+	return m.update(id, func(a *Auction) {
+		a.Phase, a.Countdown = phase, countdown
+	})
+}
+
+func (m *memStore) SetAuctionCountdown(id int, countdown int64) error {
+	return m.update(id, func(a *Auction) {
+		if a.Phase == auctionCountdown {
+			a.Countdown = countdown
+		}
+	})
+}
+
+func (m *memStore) SetAuctionBid(id int, bid *Bid, bidderID int) error {
+	return m.update(id, func(a *Auction) {
+		a.BidVal, a.BidType, a.BidderID = bid.Value, bid.CoinType, bidderID
+	})
+}
+
+func (m *memStore) SetAuctionMessage(id int, messageID int) error {
+	return m.update(id, func(a *Auction) {
+		a.MessageID = messageID
+	})
+}
+
+func (m *memStore) SetAuctionCountdownMessage(id int, messageID, previousPin int) error {
+	return m.update(id, func(a *Auction) {
+		a.CountdownMessageID, a.PreviousPinID = messageID, previousPin
+	})
+}
+
+func (m *memStore) EndAuction(id int) error {
+	return m.update(id, func(a *Auction) {
+		a.Ended, a.Phase, a.Countdown = true, auctionEnded, 0
+	})
+}
+
+// Refuses a second auction of a recurring auction at the same start, like
+// the unique constraint of the auction table.
+func (m *memStore) PutScheduledAuction(a *Auction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, other := range m.auctions {
+		if other.RecurringID == a.RecurringID && other.StartTime.Valid && other.StartTime.Time.Equal(a.StartTime.Time) {
+			return fmt.Errorf("duplicate auction of recurring auction #%d at %v", a.RecurringID, a.StartTime.Time)
+		}
+	}
+	added := *a
+	added.ID = m.nextID()
+	added.PaymentStatus = paymentUnpaid
+	added.Phase = auctionOpen
+	m.auctions = append(m.auctions, added)
+	return nil
+}
+
+// Refuses a negative limit or offset, like postgres.
+func (m *memStore) GetEndedAuctions(limit, offset int) ([]AuctionResult, error) {
+	if limit < 0 || offset < 0 {
+		return nil, fmt.Errorf("negative limit %d or offset %d", limit, offset)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var results []AuctionResult
+	for i := len(m.auctions) - 1; i >= 0; i-- {
+		if m.auctions[i].Ended {
+			results = append(results, AuctionResult{Auction: m.auctions[i]})
+		}
+	}
+	if offset > len(results) {
+		offset = len(results)
+	}
+	results = results[offset:]
+	if limit < len(results) {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+func (m *memStore) GetEndedAuctionCount() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var count int
+	for _, a := range m.auctions {
+		if a.Ended {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *memStore) GetUnsettledAuctions() ([]Auction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var unsettled []Auction
+	for _, a := range m.auctions {
+		if a.Ended && a.BidderID != 0 && a.PaymentStatus != paymentPaid {
+			unsettled = append(unsettled, a)
+		}
+	}
+	return unsettled, nil
+}
+
+func (m *memStore) SetAuctionPayment(id int, status string, confirmations int, txid string) error {
+	return m.update(id, func(a *Auction) {
+		a.PaymentStatus, a.PaymentConfirmations, a.PaymentTxID = status, confirmations, txid
+	})
+}
+
+func (m *memStore) GetClaimedPayments() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var txids []string
+	for _, a := range m.auctions {
+		if a.PaymentTxID != "" {
+			txids = append(txids, a.PaymentTxID)
+		}
+	}
+	return txids, nil
+}
+
+func (m *memStore) PutRecurringAuction(r *RecurringAuction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r.ID = m.nextID()
+	m.recurring = append(m.recurring, *r)
+	return nil
+}
+
+func (m *memStore) GetRecurringAuctions() ([]RecurringAuction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]RecurringAuction(nil), m.recurring...), nil
+}
+
+func (m *memStore) SetRecurringNextStart(id int, next time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.recurring {
+		if m.recurring[i].ID == id {
+			m.recurring[i].NextStart = next
+		}
+	}
+	return nil
+}
+
+func (m *memStore) PutCaptcha(c *Captcha) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.captchas[c.UserID] = *c
+	return nil
+}
+
+func (m *memStore) GetCaptcha(userID int) *Captcha {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, found := m.captchas[userID]
+	if !found {
+		return nil
+	}
+	return &c
+}
+
+func (m *memStore) DeleteCaptcha(userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.captchas, userID)
+	return nil
+}
+
+func (m *memStore) PutMembershipEvent(e *MembershipEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, *e)
+	return nil
+}
+
+func (m *memStore) PutAuditEntry(e *AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.audit = append(m.audit, *e)
+	return nil
+}
+
+func (m *memStore) PutStrike(s *Strike) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.ID = m.nextID()
+	s.CreatedAt = m.clock.Now()
+	m.strikes = append(m.strikes, *s)
+	return nil
+}
+
+func (m *memStore) GetActiveStrikeCount(userID int) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var count int
+	for _, s := range m.strikes {
+		if s.UserID == userID && !s.Pardoned {
+			count++
+		}
+	}
+	return count
+}
+
+func (m *memStore) PardonStrikes(userID int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int
+	for i := range m.strikes {
+		if m.strikes[i].UserID == userID && !m.strikes[i].Pardoned {
+			m.strikes[i].Pardoned = true
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *memStore) HasAuctionStrike(auctionID int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.strikes {
+		if s.AuctionID == auctionID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *memStore) PutJob(j *Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j.ID = m.nextID()
+	j.CreatedAt = m.clock.Now()
+	m.jobs = append(m.jobs, *j)
+	return nil
+}
+
+// Returns the jobs ordered like the database does, by failed, run_at and id.
+func (m *memStore) sortedJobs() []Job {
+	jobs := append([]Job(nil), m.jobs...)
+	sort.SliceStable(jobs, func(i, j int) bool {
+		if jobs[i].Failed != jobs[j].Failed {
+			return !jobs[i].Failed
+		}
+		return jobs[i].RunAt.Before(jobs[j].RunAt)
+	})
+	return jobs
+}
+
+func (m *memStore) GetDueJobs(now time.Time, limit int) ([]Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []Job
+	for _, j := range m.sortedJobs() {
+		if !j.Failed && !j.RunAt.After(now) && len(due) < limit {
+			due = append(due, j)
+		}
+	}
+	return due, nil
+}
+
+func (m *memStore) GetJobs(limit int) ([]Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := m.sortedJobs()
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, nil
+}
+
+func (m *memStore) DeleteJob(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.jobs {
+		if m.jobs[i].ID == id {
+			m.jobs = append(m.jobs[:i], m.jobs[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (m *memStore) SetJobAttempt(j *Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.jobs {
+		if m.jobs[i].ID == j.ID {
+			m.jobs[i].Attempts = j.Attempts
+			m.jobs[i].LastError = j.LastError
+			m.jobs[i].RunAt = j.RunAt
+			m.jobs[i].Failed = j.Failed
+		}
+	}
+	return nil
+}
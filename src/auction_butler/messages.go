@@ -0,0 +1,896 @@
+package auction_butler
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+// The built-in message catalog. A messages file given in the config can
+// redefine any of these templates. All messages are sent in telegram's HTML
+// mode, html/template takes care of escaping the user supplied values.
+const defaultMessages = `
+{{define "welcome"}}Welcome to the KittyCash Auction group. Please familiarise yourself with the rules in the <a href="t.me/KittyCashAuction/746">pinned message</a> before bidding on a Legendary Kitty.{{end}}
+
+{{define "join_group"}}Please join the kittycash auction group.{{end}}
+
+{{define "captcha"}}Welcome {{.Name}}! Please prove you are human: how much is {{.A}} + {{.B}}? You have {{.Timeout}} to answer.{{end}}
+
+{{define "current_bid"}}<b>Current bid of {{.Bid}}/{{.Converted}}</b>
+
+Bids only please.{{end}}
+
+{{define "auction_ends"}}Auction ends @{{.EndTime}}{{end}}
+
+{{define "auction_paused"}}The auction is paused, bids are not accepted until it resumes.{{end}}
+
+{{define "auction_resumed"}}The auction is back on and ends @{{.EndTime}}{{end}}
+
+{{define "auction_extended"}}The auction got extended and ends @{{.EndTime}}{{end}}
+
+{{define "auction_cancelled"}}Auction #{{.ID}} got cancelled without a winner: {{.Reason}}{{end}}
+
+{{define "auction_info"}}Auction End Time: {{.EndTime}}{{end}}
+
+{{define "countdown"}}{{.Count}}{{end}}
+
+{{define "live_countdown"}}<b>{{.Count}}</b>
+{{if .Leader}}Highest bid: {{.Bid}} by {{.Leader}}{{else}}No bids yet{{end}}
+About {{.Left}} left{{end}}
+
+{{define "winner"}}{{if .Winner}}{{.Winner}} won{{if .Lot}} {{.Lot}}{{end}} with {{.Bid}}. {{end}}Please PM @erichkaestner{{end}}
+
+{{define "bidding_banned"}}You are not allowed to bid.{{end}}
+
+{{define "chat_warning"}}Only bids are allowed in the group right now, so your message was deleted. You will be muted after {{.Left}} more.{{end}}
+
+{{define "chat_muted"}}You are muted until {{.Until}} for posting messages that are not bids.{{end}}
+
+{{define "bidding_suspended"}}Your bidding is suspended until {{.Until}} because of {{.Strikes}} strikes on your record.{{end}}
+
+{{define "results"}}
+{{- if .Results -}}
+Auctions {{.From}}-{{.To}} of {{.Total}}:
+{{- range .Results}}
+{{template "result" .}}
+{{- end}}
+{{- else -}}
+No auction results yet.
+{{- end}}
+{{- end}}
+
+{{define "result"}}
+{{- if .Winner -}}
+#{{.ID}} ended {{.EndTime}}: {{.Bid}} by {{.Winner}} ({{.Bids}} bids)
+{{- else -}}
+#{{.ID}} ended {{.EndTime}}: no bids
+{{- end}}
+{{- end}}
+
+{{define "results_newer"}}« Newer{{end}}
+
+{{define "results_older"}}Older »{{end}}
+
+{{define "auction"}}
+{{- if .Ended -}}
+Auction #{{.ID}}
+Ended: {{.EndTime}}
+{{- if .Winner}}
+Winning bid: {{.Bid}}/{{.Converted}}
+Winner: {{.Winner}}
+Payment: {{.Payment}}
+{{- end}}
+{{- else -}}
+Auction #{{.ID}}
+Ends: {{.EndTime}}
+{{- if .Winner}}
+Leading bid: {{.Bid}}/{{.Converted}}
+Leader: {{.Winner}}
+{{- end}}
+{{- end}}
+Bids: {{.Bids}} from {{.Bidders}} bidders
+{{- end}}
+
+{{define "my_bids"}}
+{{- if .Bids -}}
+Your bids in running auctions:
+{{- range .Bids}}
+#{{.ID}} ends {{.EndTime}}: {{.Bid}} ({{if .Leading}}leading{{else}}outbid, current bid is {{.Current}}{{end}})
+{{- end}}
+{{- else -}}
+You have no bids in running auctions.
+{{- end}}
+{{- end}}
+
+{{define "my_wins"}}
+{{- if .Wins -}}
+Your won auctions:
+{{- range .Wins}}
+#{{.ID}} ended {{.EndTime}}: {{.Bid}} ({{.Payment}})
+{{- end}}
+{{- else -}}
+You have not won any auctions yet.
+{{- end}}
+{{- end}}
+
+{{define "my_stats"}}Bids placed: {{.Bids}}
+Auctions joined: {{.Auctions}}
+Auctions won: {{.Wins}}
+Total spent: {{if .Spent}}{{.Spent}}{{else}}nothing{{end}}
+{{- if .Since}}
+Bidding since: {{.Since}}
+{{- end}}
+{{- end}}
+
+{{define "leaderboard"}}
+{{- if .Entries -}}
+Top {{.Metric}} ({{.Window}}):
+{{- range .Entries}}
+{{.Rank}}. {{.Name}} - {{.Score}}
+{{- end}}
+{{- else -}}
+Nobody is on the leaderboard yet.
+{{- end}}
+{{- end}}
+
+{{define "language"}}Your language is {{.Language}}. Available languages: {{.Languages}}.{{end}}
+
+{{define "language_set"}}Your language is {{.Language}} now.{{end}}
+
+{{define "timezone"}}Your time zone is {{.Zone}}, it is {{.Time}} there now.{{end}}
+
+{{define "command_failed"}}command failed: {{.Error}}{{end}}
+
+{{define "callback_failed"}}failed: {{.Error}}{{end}}
+
+{{define "no_action"}}no action required{{end}}
+
+{{define "not_member"}}that user is not a member of the chat{{end}}
+
+{{define "user_enabled"}}{{range $i, $action := .Actions}}{{if $i}}, {{end}}{{$action}}{{end}}{{end}}
+
+{{define "user_banned"}}{{.Name}} banned{{if .Error}}, but kicking from the group failed: {{.Error}}{{end}}{{end}}
+
+{{define "user_unbanned"}}{{.Name}} unbanned{{if .Error}}, but the group ban may still be in place: {{.Error}}{{end}}{{end}}
+
+{{define "users"}}
+{{- if .Users -}}
+{{len .Users}} users:
+{{- range .Users}}
+{{.ID}} {{.Name}}
+{{- end}}
+{{- else -}}
+no users
+{{- end}}
+{{- end}}
+
+{{define "whois"}}ID: {{.ID}}
+Username: {{.UserName}}
+Name: {{.Name}}
+Enlisted: {{.Enlisted}}
+Banned: {{.Banned}}
+Role: {{.Role}}
+Strikes: {{.Strikes}}
+{{- if .SuspendedUntil}}
+Suspended until: {{.SuspendedUntil}}
+{{- end}}
+{{- if .Joined}}
+Joined: {{.Joined}}
+{{- end}}
+{{- if .PreviousNames}}
+Previous usernames: {{range $i, $name := .PreviousNames}}{{if $i}}, {{end}}{{$name.UserName}} (until {{$name.Until}}){{end}}
+{{- end}}
+{{- range .Events}}
+{{.Time}}: {{.Event}}{{if .Actor}} by {{.Actor}}{{end}}
+{{- end}}
+{{- end}}
+
+{{define "strike_added"}}{{.Name}} now has {{.Count}} strikes{{if .Banned}}, banned{{else if .SuspendedUntil}}, suspended until {{.SuspendedUntil}}{{end}}{{end}}
+
+{{define "unpaid_strike"}}Auction #{{.ID}} was not paid in time: {{template "strike_added" .Strike}}.{{end}}
+
+{{define "strikes"}}{{.Name}}
+{{- if .SuspendedUntil}}
+suspended until {{.SuspendedUntil}}
+{{- end}}
+{{- range .Strikes}}
+{{.Time}} {{.Kind}}{{if .Reason}}: {{.Reason}}{{end}}{{if .Pardoned}} (pardoned){{end}}
+{{- else}}
+no strikes
+{{- end}}
+{{- end}}
+
+{{define "pardoned"}}{{.Name}} pardoned, {{.Count}} strikes cleared{{end}}
+
+{{define "audit"}}
+{{- range .Entries}}
+{{.Time}} {{.Action}} {{.Target}} by {{.Actor}}{{if .Reason}}: {{.Reason}}{{end}}{{if .Message}} {{printf "%q" .Message}}{{end}}
+{{- else -}}
+audit log is empty
+{{- end}}
+{{- end}}
+
+{{define "role_set"}}{{.Name}} is {{.Role}} now{{end}}
+
+{{define "roles"}}
+{{- range .Users}}
+{{.Role}}: {{.ID}} {{.Name}}
+{{- else -}}
+everybody is a bidder
+{{- end}}
+{{- end}}
+
+{{define "stats"}}Ended auctions: {{.Total}} ({{.Auctions}} with bids)
+Average bids per auction: {{printf "%.1f" .AvgBids}}
+Average bidders per auction: {{printf "%.1f" .AvgBidders}}
+Average final/opening price: {{printf "%.2f" .AvgPriceRatio}}
+Total sold: {{printf "%.0f" .TotalSold}} SKY{{end}}
+
+{{define "payment_confirmed"}}Payment of {{.Bid}} for auction #{{.ID}} is confirmed.{{end}}
+
+{{define "confirm_end_time"}}The auction will end {{.EndTime}}. Create it?{{end}}
+
+{{define "confirm"}}Confirm{{end}}
+
+{{define "cancel"}}Cancel{{end}}
+
+{{define "auction_created"}}Auction created, it ends {{.EndTime}}.{{end}}
+
+{{define "not_created"}}Cancelled, no auction created.{{end}}
+
+{{define "paused"}}auction #{{.ID}} paused with {{.Left}} left{{end}}
+
+{{define "resumed"}}auction #{{.ID}} resumed, it ends {{.EndTime}}{{end}}
+
+{{define "extended"}}auction #{{.ID}} extended, {{if .Paused}}it has {{.Left}} left once resumed{{else}}it ends {{.EndTime}}{{end}}{{end}}
+
+{{define "cancelled"}}auction #{{.ID}} cancelled, {{.Bidders}} bidders notified{{end}}
+
+{{define "reminders"}}Auction #{{.ID}} ends {{.EndTime}}
+Reminders: {{.Plan}}
+{{- range .Events}}
+{{.Time}} (in {{.In}}): {{if .Countdown}}countdown{{else}}reminder{{end}}
+{{- end}}
+{{- end}}
+
+{{define "recurring_auction"}}#{{.ID}} {{.Schedule}} for {{.Duration}}
+{{- if .Lot}}, lot {{.Lot}}{{end}}
+{{- if .StartBid}}, starting {{.StartBid}}{{end}}
+{{- if .Reminders}}, reminders {{.Reminders}}{{end}}
+{{- if .Paused}}, paused{{else if .Until}}, auctions created until {{.Until}}{{else}}, no more auctions{{end}}
+{{- end}}
+
+{{define "recurring"}}
+{{- range .Recurring}}
+{{template "recurring_auction" .}}
+{{- else -}}
+no recurring auctions
+{{- end}}
+{{- end}}
+
+{{define "recurring_added"}}added {{template "recurring_auction" .}}{{end}}
+
+{{define "recurring_resumed"}}resumed {{template "recurring_auction" .}}{{end}}
+
+{{define "recurring_paused"}}recurring auction #{{.ID}} paused, {{.Deleted}} upcoming auctions deleted{{end}}
+
+{{define "recurring_deleted"}}recurring auction #{{.ID}} deleted, {{.Deleted}} upcoming auctions with it{{end}}
+
+{{define "jobs"}}
+{{- range .Jobs}}
+#{{.ID}} {{.Kind}} {{if .Failed}}failed{{else}}at {{.RunAt}}{{end}}{{if .Attempts}}, {{.Attempts}} attempts, last error: {{.LastError}}{{end}}
+{{- else -}}
+no pending jobs
+{{- end}}
+{{- end}}
+
+{{define "help"}}
+/start
+/help - this text
+/getauctioninfo - returns info of current auction
+/results [n](optional) - list the results of recent auctions
+/auction [id] - show the summary of an auction
+{{- if .Bid}}
+/mybids - your bids in running auctions
+/mywins - auctions you have won
+/mystats - your bidding statistics
+{{- end}}
+/leaderboard [won|spent|bids](optional) [week|month|all](optional) - top members
+/language [code](optional) - show or change the language the bot talks to you in
+/timezone [zone|default](optional) - show or change the time zone times are shown in, e.g. Europe/Berlin or CET
+{{- if .Auction}}
+/setauctioninfo [end_time] [auction_info](optional) - set details for current auction, the end time may be relative like "in 2h30m" or "+90m" and name a zone like "18:00 Europe/Berlin" or "18:00 CET"
+/pauseauction - stop accepting bids and freeze the time left
+/resumeauction - continue a paused auction
+/extend [duration] - move the end of the current auction, e.g. /extend 30m
+/cancelauction [reason] - close the current auction without a winner and notify its bidders
+/stats - auction statistics
+/reminders - preview the reminders and the countdown of the current auction
+/setreminders [offset...|decay first factor min|every interval|default] - change the reminders of the current auction
+/recurring - list recurring auctions
+/addrecurring [cron expression|every interval] for [duration] starting [bid](optional) lot [name](optional) reminders [plan](optional) - add an auction that repeats, e.g. /addrecurring 0 18 * * * for 2h starting 10 SKY lot daily kitty
+/pauserecurring [id] - stop creating auctions of a recurring auction and delete its upcoming ones
+/resumerecurring [id] - create auctions of a paused recurring auction again
+/deleterecurring [id] - delete a recurring auction and its upcoming auctions
+{{- end}}
+{{- if .Moderate}}
+/strike [user] [unpaid|retracted|moderation] [reason](optional) - give a strike to a user
+/strikes [user] - list strikes of a user
+/pardon [user] - clear strikes, suspension and ban of a user
+/ban [user] - ban a user and kick them from the group
+/unban [user] - unban a user and let them rejoin the group
+/users [banned](optional) - list tracked users
+/whois [user] - show what is known about a user
+/audit [user](optional) - show the latest moderation actions
+/roles - list users with other roles than bidder
+{{- end}}
+{{- if .Manage}}
+/role [user] [owner|admin|auctioneer|moderator|bidder|viewer] - give a role to a user
+/promote [user] - make a user an admin
+/demote [user] - make a user a bidder
+/jobs - list pending and failed delayed jobs
+{{- end}}
+{{- if or .Moderate .Manage}}
+
+Instead of [user] you can give a username or id, or reply with the command to a message of the user.
+{{- end}}
+{{- end}}
+`
+
+type captchaMessage struct {
+	Name    string
+	A, B    int
+	Timeout string
+}
+
+type bidMessage struct {
+	Bid       string
+	Converted string
+}
+
+type endTimeMessage struct {
+	EndTime string
+}
+
+type cancelledMessage struct {
+	ID     int
+	Reason string
+}
+
+type countdownMessage struct {
+	Count int64
+}
+
+type liveCountdownMessage struct {
+	Count  int64
+	Bid    string
+	Leader string
+	Left   string
+}
+
+type winnerMessage struct {
+	Winner string
+	Bid    string
+	Lot    string
+}
+
+type chatWarningMessage struct {
+	Left int
+}
+
+type chatMutedMessage struct {
+	Until string
+}
+
+type suspendedMessage struct {
+	Until   string
+	Strikes int
+}
+
+type resultLine struct {
+	ID      int
+	EndTime string
+	Bid     string
+	Winner  string
+	Bids    int
+	Payment string
+}
+
+type resultsMessage struct {
+	From    int
+	To      int
+	Total   int
+	Results []resultLine
+}
+
+type auctionMessage struct {
+	ID        int
+	Ended     bool
+	EndTime   string
+	Bid       string
+	Converted string
+	Winner    string
+	Payment   string
+	Bids      int
+	Bidders   int
+}
+
+type myBidLine struct {
+	ID      int
+	EndTime string
+	Bid     string
+	Leading bool
+	Current string
+}
+
+type myBidsMessage struct {
+	Bids []myBidLine
+}
+
+type myWinsMessage struct {
+	Wins []resultLine
+}
+
+type myStatsMessage struct {
+	Bids     int
+	Auctions int
+	Wins     int
+	Spent    string
+	Since    string
+}
+
+type leaderboardLine struct {
+	Rank  int
+	Name  string
+	Score string
+}
+
+type leaderboardMessage struct {
+	Metric  string
+	Window  string
+	Entries []leaderboardLine
+}
+
+// Which sections of the help to show, by the permissions of the user
+type helpMessage struct {
+	Bid      bool
+	Auction  bool
+	Moderate bool
+	Manage   bool
+}
+
+type timezoneMessage struct {
+	Zone string
+	Time string
+}
+
+type languageMessage struct {
+	Language  string
+	Languages string
+}
+
+type errorMessage struct {
+	Error string
+}
+
+// What enabling a user did: created, unbanned and/or enlisted
+type enabledMessage struct {
+	Actions []string
+}
+
+// A moderation action on a user, with the error of its telegram side if
+// that failed
+type userActionMessage struct {
+	Name  string
+	Error string
+}
+
+type userLine struct {
+	ID   int
+	Name string
+}
+
+type usersMessage struct {
+	Users []userLine
+}
+
+type previousName struct {
+	UserName string
+	Until    string
+}
+
+type membershipLine struct {
+	Time  string
+	Event string
+	Actor string
+}
+
+type whoisMessage struct {
+	ID             int
+	UserName       string
+	Name           string
+	Enlisted       bool
+	Banned         bool
+	Role           string
+	Strikes        int
+	SuspendedUntil string
+	Joined         string
+	PreviousNames  []previousName
+	Events         []membershipLine
+}
+
+type strikeMessage struct {
+	Name           string
+	Count          int
+	Banned         bool
+	SuspendedUntil string
+}
+
+type unpaidStrikeMessage struct {
+	ID     int
+	Strike strikeMessage
+}
+
+type strikeLine struct {
+	Time     string
+	Kind     string
+	Reason   string
+	Pardoned bool
+}
+
+type strikesMessage struct {
+	Name           string
+	SuspendedUntil string
+	Strikes        []strikeLine
+}
+
+type pardonMessage struct {
+	Name  string
+	Count int
+}
+
+type auditLine struct {
+	Time    string
+	Action  string
+	Target  string
+	Actor   string
+	Reason  string
+	Message string
+}
+
+type auditMessage struct {
+	Entries []auditLine
+}
+
+type roleMessage struct {
+	Name string
+	Role string
+}
+
+type roleLine struct {
+	Role string
+	ID   int
+	Name string
+}
+
+type rolesMessage struct {
+	Users []roleLine
+}
+
+type statsMessage struct {
+	Total         int
+	Auctions      int
+	AvgBids       float64
+	AvgBidders    float64
+	AvgPriceRatio float64
+	TotalSold     float64
+}
+
+type paymentMessage struct {
+	ID  int
+	Bid string
+}
+
+// The outcome of pausing, resuming, extending or cancelling an auction
+type controlMessage struct {
+	ID      int
+	EndTime string
+	Left    string
+	Paused  bool
+	Bidders int
+}
+
+type reminderLine struct {
+	Time      string
+	In        string
+	Countdown bool
+}
+
+type remindersMessage struct {
+	ID      int
+	EndTime string
+	Plan    string
+	Events  []reminderLine
+}
+
+type recurringLine struct {
+	ID        int
+	Schedule  string
+	Duration  string
+	Lot       string
+	StartBid  string
+	Reminders string
+	Paused    bool
+	Until     string
+}
+
+type recurringMessage struct {
+	Recurring []recurringLine
+}
+
+type recurringChangeMessage struct {
+	ID      int
+	Deleted int
+}
+
+type jobLine struct {
+	ID        int
+	Kind      string
+	RunAt     string
+	Failed    bool
+	Attempts  int
+	LastError string
+}
+
+type jobsMessage struct {
+	Jobs []jobLine
+}
+
+// Every template the bot uses, with sample data to check it at startup
+var messageSamples = map[string]interface{}{
+	"welcome":           nil,
+	"join_group":        nil,
+	"captcha":           captchaMessage{},
+	"current_bid":       bidMessage{},
+	"auction_ends":      endTimeMessage{},
+	"auction_info":      endTimeMessage{},
+	"auction_paused":    nil,
+	"auction_resumed":   endTimeMessage{},
+	"auction_extended":  endTimeMessage{},
+	"auction_cancelled": cancelledMessage{},
+	"countdown":         countdownMessage{},
+	"live_countdown":    liveCountdownMessage{},
+	"winner":            winnerMessage{},
+	"bidding_banned":    nil,
+	"bidding_suspended": suspendedMessage{},
+	"chat_warning":      chatWarningMessage{},
+	"chat_muted":        chatMutedMessage{},
+	"results":           resultsMessage{Results: []resultLine{{}}},
+	"results_newer":     nil,
+	"results_older":     nil,
+	"auction":           auctionMessage{},
+	"my_bids":           myBidsMessage{Bids: []myBidLine{{}}},
+	"my_wins":           myWinsMessage{Wins: []resultLine{{}}},
+	"my_stats":          myStatsMessage{},
+	"leaderboard":       leaderboardMessage{Entries: []leaderboardLine{{}}},
+	"help":              helpMessage{true, true, true, true},
+	"language":          languageMessage{},
+	"language_set":      languageMessage{},
+	"timezone":          timezoneMessage{},
+	"command_failed":    errorMessage{},
+	"callback_failed":   errorMessage{},
+	"no_action":         nil,
+	"not_member":        nil,
+	"user_enabled":      enabledMessage{Actions: []string{""}},
+	"user_banned":       userActionMessage{},
+	"user_unbanned":     userActionMessage{},
+	"users":             usersMessage{Users: []userLine{{}}},
+	"whois":             whoisMessage{PreviousNames: []previousName{{}}, Events: []membershipLine{{}}},
+	"strike_added":      strikeMessage{},
+	"unpaid_strike":     unpaidStrikeMessage{},
+	"strikes":           strikesMessage{Strikes: []strikeLine{{}}},
+	"pardoned":          pardonMessage{},
+	"audit":             auditMessage{Entries: []auditLine{{}}},
+	"role_set":          roleMessage{},
+	"roles":             rolesMessage{Users: []roleLine{{}}},
+	"stats":             statsMessage{},
+	"payment_confirmed": paymentMessage{},
+	"confirm_end_time":  endTimeMessage{},
+	"confirm":           nil,
+	"cancel":            nil,
+	"auction_created":   endTimeMessage{},
+	"not_created":       nil,
+	"paused":            controlMessage{},
+	"resumed":           controlMessage{},
+	"extended":          controlMessage{},
+	"cancelled":         controlMessage{},
+	"reminders":         remindersMessage{Events: []reminderLine{{}}},
+	"recurring":         recurringMessage{Recurring: []recurringLine{{}}},
+	"recurring_added":   recurringLine{},
+	"recurring_resumed": recurringLine{},
+	"recurring_paused":  recurringChangeMessage{},
+	"recurring_deleted": recurringChangeMessage{},
+	"jobs":              jobsMessage{Jobs: []jobLine{{}}},
+}
+
+type Messages struct {
+	templates *template.Template
+}
+
+// Parses the built-in catalog and the templates of the given files on top of
+// it, later files override the earlier ones. Then checks that every message
+// renders.
+func LoadMessages(filenames ...string) (*Messages, error) {
+	templates, err := template.New("messages").Parse(defaultMessages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse built-in messages: %v", err)
+	}
+
+	for _, filename := range filenames {
+		if filename == "" {
+			continue
+		}
+		text, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read messages from file '%s': %v", filename, err)
+		}
+		if templates, err = templates.Parse(string(text)); err != nil {
+			return nil, fmt.Errorf("failed to parse messages from file '%s': %v", filename, err)
+		}
+	}
+
+	messages := &Messages{templates}
+	for name, sample := range messageSamples {
+		if _, err := messages.Render(name, sample); err != nil {
+			return nil, fmt.Errorf("invalid message template: %v", err)
+		}
+	}
+
+	return messages, nil
+}
+
+func (m *Messages) Render(name string, data interface{}) (string, error) {
+	if m.templates.Lookup(name) == nil {
+		return "", fmt.Errorf("message %s is not defined", name)
+	}
+
+	var buf bytes.Buffer
+	if err := m.templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// Message catalogs by language code
+type Catalog map[string]*Messages
+
+// Loads the catalog of the default language and of every translation. Each
+// translation falls back to the default messages for what it does not define.
+func LoadCatalog(config *Config) (Catalog, error) {
+	catalog := make(Catalog)
+
+	messages, err := LoadMessages(config.MessagesFile)
+	if err != nil {
+		return nil, err
+	}
+	catalog[config.Language] = messages
+
+	for language, filename := range config.Translations {
+		if catalog[normalizeLanguage(language)], err = LoadMessages(config.MessagesFile, filename); err != nil {
+			return nil, fmt.Errorf("%s: %v", language, err)
+		}
+	}
+
+	return catalog, nil
+}
+
+func (c Catalog) Languages() []string {
+	var languages []string
+	for language := range c {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Turns telegram's language codes like "ru-RU" into catalog keys like "ru"
+func normalizeLanguage(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	return code
+}
+
+// Returns the language to talk to the user in. A nil user means the group.
+func (bot *Bot) language(u *User) string {
+	if u != nil {
+		if _, found := bot.messages[u.Language]; found {
+			return u.Language
+		}
+	}
+	return bot.config.Language
+}
+
+// Remembers the language telegram reports for the user, unless they have
+// chosen one already or there is no catalog for it.
+func (bot *Bot) adoptLanguage(u *User, code string) {
+	language := normalizeLanguage(code)
+	if u.Language != "" || language == "" {
+		return
+	}
+	if _, found := bot.messages[language]; !found {
+		return
+	}
+
+	u.Language = language
+	if err := bot.db.PutUser(u); err != nil {
+		log.Printf("failed to save language of %s: %v", u.NameAndTags(), err)
+	}
+}
+
+// Renders the message in the language of the user (the group's if the user
+// is nil), or returns its name if it fails to render, which the startup
+// check makes unlikely.
+func (bot *Bot) text(u *User, name string, data interface{}) string {
+	text, err := bot.messages[bot.language(u)].Render(name, data)
+	if err != nil {
+		log.Printf("failed to render message %s: %v", name, err)
+		return name
+	}
+	return text
+}
+
+// Sends the message in the group's language when yelling and in the user's
+// language otherwise.
+func (bot *Bot) SendTemplate(ctx *Context, mode, name string, data interface{}) (*tgbotapi.Message, error) {
+	var u *User
+	if mode != "yell" {
+		u = ctx.User
+	}
+	return bot.Send(ctx, mode, "html", bot.text(u, name, data))
+}
+
+func (bot *Bot) ReplyTemplate(ctx *Context, name string, data interface{}) error {
+	_, err := bot.SendTemplate(ctx, "reply", name, data)
+	return err
+}
+
+// Replies with a message that may grow too long for one, like a list,
+// split at line ends.
+func (bot *Bot) replyTemplateLines(ctx *Context, name string, data interface{}) error {
+	return bot.replyLines(ctx, strings.Split(bot.text(ctx.User, name, data), "\n"))
+}
+
+// Renders the message for the places telegram shows as plain text, like the
+// answers to button presses.
+func (bot *Bot) plainText(u *User, name string, data interface{}) string {
+	return html.UnescapeString(bot.text(u, name, data))
+}
+
+func (bot *Bot) handleCommandLanguage(ctx *Context, command, args string) error {
+	language := normalizeLanguage(args)
+	if language == "" {
+		return bot.ReplyTemplate(ctx, "language", languageMessage{
+			Language:  bot.language(ctx.User),
+			Languages: strings.Join(bot.messages.Languages(), ", "),
+		})
+	}
+
+	if _, found := bot.messages[language]; !found {
+		return fmt.Errorf("unsupported language: %s, choose one of: %s", language, strings.Join(bot.messages.Languages(), ", "))
+	}
+
+	ctx.User.Language = language
+	if err := bot.db.PutUser(ctx.User); err != nil {
+		return fmt.Errorf("failed to save language: %v", err)
+	}
+
+	return bot.ReplyTemplate(ctx, "language_set", languageMessage{Language: language})
+}
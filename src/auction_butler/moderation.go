@@ -0,0 +1,235 @@
+package auction_butler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+// Telegram rejects messages longer than this
+const maxMessageLength = 4096
+
+// Returns the author of the message the command replies to, or the original
+// author if that message was forwarded.
+func (bot *Bot) repliedUser(ctx *Context) *User {
+	re := ctx.message.ReplyToMessage
+	if re == nil {
+		return nil
+	}
+	from := re.From
+	if re.ForwardFrom != nil {
+		from = re.ForwardFrom
+	}
+	if from == nil || from.ID == bot.telegram.Self.ID {
+		return nil
+	}
+
+	if user := bot.db.GetUser(from.ID); user != nil {
+		return user
+	}
+	return &User{
+		ID:        from.ID,
+		UserName:  from.UserName,
+		FirstName: from.FirstName,
+		LastName:  from.LastName,
+	}
+}
+
+// Tells whether the word can only be meant as a user, an @username or an id.
+func namesUser(word string) bool {
+	if strings.HasPrefix(word, "@") {
+		return true
+	}
+	_, err := strconv.Atoi(word)
+	return err == nil
+}
+
+// Looks up the user a command is about: either the first word of the
+// arguments (a username or an id) or the author of the replied message.
+// Returns the remaining words of the arguments.
+func (bot *Bot) targetUser(ctx *Context, args string) (*User, []string, error) {
+	words := strings.Fields(args)
+	if len(words) > 0 {
+		if user := bot.db.GetUserByNameOrId(strings.TrimPrefix(words[0], "@")); user != nil {
+			return user, words[1:], nil
+		}
+	}
+
+	// a user that is not found is an error even when replying, rather than
+	// acting on the replied user instead
+	if user := bot.repliedUser(ctx); user != nil && (len(words) == 0 || !namesUser(words[0])) {
+		return user, words, nil
+	}
+
+	if len(words) == 0 {
+		return nil, nil, fmt.Errorf("insufficient arguments: give a username or id, or reply to a message of the user")
+	}
+	return nil, nil, fmt.Errorf("user not found: %s", words[0])
+}
+
+func (bot *Bot) chatMember(userID int) tgbotapi.ChatMemberConfig {
+	return tgbotapi.ChatMemberConfig{ChatID: bot.config.ChatID, UserID: userID}
+}
+
+// Takes away (or gives back) the right to post in the group until the given
+// time, forever if it is zero.
+func (bot *Bot) restrict(userID int, canSend bool, until time.Time) error {
+	config := tgbotapi.RestrictChatMemberConfig{
+		ChatMemberConfig:      bot.chatMember(userID),
+		CanSendMessages:       &canSend,
+		CanSendMediaMessages:  &canSend,
+		CanSendOtherMessages:  &canSend,
+		CanAddWebPagePreviews: &canSend,
+	}
+	if !until.IsZero() {
+		config.UntilDate = until.Unix()
+	}
+	_, err := bot.telegram.RestrictChatMember(config)
+	return err
+}
+
+// Sends the lines of html as replies, split into as few messages as telegram
+// allows.
+func (bot *Bot) replyLines(ctx *Context, lines []string) error {
+	var chunk []string
+	var length int
+	for _, line := range lines {
+		if length+len(line)+1 > maxMessageLength && len(chunk) > 0 {
+			if _, err := bot.Send(ctx, "reply", "html", strings.Join(chunk, "\n")); err != nil {
+				return err
+			}
+			chunk, length = nil, 0
+		}
+		chunk = append(chunk, line)
+		length += len(line) + 1
+	}
+	if len(chunk) == 0 {
+		return nil
+	}
+	_, err := bot.Send(ctx, "reply", "html", strings.Join(chunk, "\n"))
+	return err
+}
+
+func (bot *Bot) handleCommandBan(ctx *Context, command, args string) error {
+	user, words, err := bot.targetUser(ctx, args)
+	if err != nil {
+		return err
+	}
+	if user.IsStaff() {
+		return fmt.Errorf("%s is %s, demote them first", user.Name(), user.role())
+	}
+
+	user.Banned = true
+	user.BannedByStrikes = false
+	user.Enlisted = false
+	if err := bot.db.PutUser(user); err != nil {
+		return fmt.Errorf("failed to change user status: %v", err)
+	}
+	bot.audit(ctx.User, user.ID, auditBan, strings.Join(words, " "), "")
+
+	if _, err := bot.telegram.KickChatMember(tgbotapi.KickChatMemberConfig{ChatMemberConfig: bot.chatMember(user.ID)}); err != nil {
+		log.Printf("failed to kick %s from the group: %v", user.NameAndTags(), err)
+		return bot.ReplyTemplate(ctx, "user_banned", userActionMessage{user.Name(), err.Error()})
+	}
+	bot.logMembership(user.ID, memberKick, ctx.User.ID)
+
+	log.Printf("banned: %s", user.NameAndTags())
+	return bot.ReplyTemplate(ctx, "user_banned", userActionMessage{Name: user.Name()})
+}
+
+func (bot *Bot) handleCommandUnban(ctx *Context, command, args string) error {
+	user, words, err := bot.targetUser(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	user.Banned = false
+	user.BannedByStrikes = false
+	if err := bot.db.PutUser(user); err != nil {
+		return fmt.Errorf("failed to change user status: %v", err)
+	}
+	bot.audit(ctx.User, user.ID, auditUnban, strings.Join(words, " "), "")
+
+	// lift the telegram ban so the user can join the group again. Users who
+	// were only banned from bidding are still members, and unbanning a member
+	// removes them from the group.
+	member, err := bot.telegram.GetChatMember(tgbotapi.ChatConfigWithUser{ChatID: bot.config.ChatID, UserID: user.ID})
+	if err != nil {
+		log.Printf("failed to get the membership of %s: %v", user.NameAndTags(), err)
+		return bot.ReplyTemplate(ctx, "user_unbanned", userActionMessage{user.Name(), err.Error()})
+	}
+	if member.WasKicked() {
+		if _, err := bot.telegram.UnbanChatMember(bot.chatMember(user.ID)); err != nil {
+			log.Printf("failed to unban %s in the group: %v", user.NameAndTags(), err)
+			return bot.ReplyTemplate(ctx, "user_unbanned", userActionMessage{user.Name(), err.Error()})
+		}
+	}
+
+	log.Printf("unbanned: %s", user.NameAndTags())
+	return bot.ReplyTemplate(ctx, "user_unbanned", userActionMessage{Name: user.Name()})
+}
+
+func (bot *Bot) handleCommandUsers(ctx *Context, command, args string) error {
+	banned := strings.TrimSpace(args) == "banned"
+	users, err := bot.db.GetUsers(banned)
+	if err != nil {
+		return fmt.Errorf("failed to get users: %v", err)
+	}
+
+	var msg usersMessage
+	for _, user := range users {
+		msg.Users = append(msg.Users, userLine{user.ID, user.NameAndTags()})
+	}
+	return bot.replyTemplateLines(ctx, "users", msg)
+}
+
+func (bot *Bot) handleCommandWhois(ctx *Context, command, args string) error {
+	user, _, err := bot.targetUser(ctx, args)
+	if err != nil {
+		return err
+	}
+	if !user.Exists() {
+		return fmt.Errorf("%s is not tracked", user.Name())
+	}
+
+	msg := whoisMessage{
+		ID:       user.ID,
+		UserName: user.UserName,
+		Name:     strings.TrimSpace(user.FirstName + " " + user.LastName),
+		Enlisted: user.Enlisted,
+		Banned:   user.Banned,
+		Role:     user.role(),
+		Strikes:  bot.db.GetActiveStrikeCount(user.ID),
+	}
+	if user.SuspendedUntil.Valid {
+		msg.SuspendedUntil = niceTime(user.SuspendedUntil.Time.UTC())
+	}
+	if user.JoinedAt.Valid {
+		msg.Joined = niceTime(user.JoinedAt.Time.UTC())
+	}
+
+	changes, err := bot.db.GetUsernameHistory(user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get username history: %v", err)
+	}
+	for _, change := range changes {
+		msg.PreviousNames = append(msg.PreviousNames, previousName{change.UserName, niceTime(change.ChangedAt.UTC())})
+	}
+
+	events, err := bot.db.GetMembershipEvents(user.ID, membershipHistorySize)
+	if err != nil {
+		return fmt.Errorf("failed to get membership history: %v", err)
+	}
+	for _, event := range events {
+		line := membershipLine{Time: niceTime(event.CreatedAt.UTC()), Event: event.Event}
+		if event.ActorID != 0 {
+			line.Actor = bot.userName(event.ActorID)
+		}
+		msg.Events = append(msg.Events, line)
+	}
+
+	return bot.ReplyTemplate(ctx, "whois", msg)
+}
@@ -0,0 +1,344 @@
+package auction_butler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Settlement states of a closed auction
+const (
+	paymentUnpaid  = "unpaid"
+	paymentPending = "pending"
+	paymentPaid    = "paid"
+)
+
+// A confirmed payment to one of the configured addresses
+type Payment struct {
+	TxID          string
+	Amount        float64
+	Time          time.Time
+	Confirmations int
+}
+
+// PaymentWatcher lists the confirmed payments in `currency` to `address`
+// made after `since`.
+type PaymentWatcher interface {
+	Payments(address, currency string, since time.Time) ([]Payment, error)
+}
+
+// Amounts closer than this are equal, it is the smallest unit of bitcoin
+const paymentPrecision = 1e-8
+
+var paymentHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+func getJSON(url string, result interface{}) error {
+	resp, err := paymentHTTPClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// SkycoinWatcher checks payments using the REST API of a skycoin node.
+type SkycoinWatcher struct {
+	NodeURL string
+}
+
+type skycoinTransaction struct {
+	Status struct {
+		Confirmed bool `json:"confirmed"`
+		// number of blocks the transaction is buried under (including its own)
+		Height int `json:"height"`
+	} `json:"status"`
+	Txn struct {
+		TxID      string `json:"txid"`
+		Timestamp int64  `json:"timestamp"`
+		Outputs   []struct {
+			Dst   string `json:"dst"`
+			Coins string `json:"coins"`
+		} `json:"outputs"`
+	} `json:"txn"`
+}
+
+func (w *SkycoinWatcher) Payments(address, currency string, since time.Time) ([]Payment, error) {
+	if currency != "SKY" {
+		return nil, fmt.Errorf("unsupported currency for skycoin node: %s", currency)
+	}
+
+	var txns []skycoinTransaction
+	endpoint := fmt.Sprintf("%s/api/v1/transactions?addrs=%s", strings.TrimRight(w.NodeURL, "/"), url.QueryEscape(address))
+	if err := getJSON(endpoint, &txns); err != nil {
+		return nil, fmt.Errorf("failed to get transactions from skycoin node: %v", err)
+	}
+
+	var payments []Payment
+	for _, txn := range txns {
+		at := time.Unix(txn.Txn.Timestamp, 0)
+		if !txn.Status.Confirmed || at.Before(since) {
+			continue
+		}
+		var received float64
+		for _, out := range txn.Txn.Outputs {
+			if out.Dst != address {
+				continue
+			}
+			coins, err := strconv.ParseFloat(out.Coins, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid coins in skycoin output: %v", err)
+			}
+			received += coins
+		}
+		if received > 0 {
+			payments = append(payments, Payment{txn.Txn.TxID, received, at, txn.Status.Height})
+		}
+	}
+
+	return payments, nil
+}
+
+// BitcoinWatcher checks payments using an Esplora compatible block explorer
+// API (blockstream.info, mempool.space or a self-hosted instance).
+type BitcoinWatcher struct {
+	ExplorerURL string
+}
+
+// Esplora lists this many confirmed transactions of an address at a time
+const esploraPageSize = 25
+
+type bitcoinTransaction struct {
+	TxID   string `json:"txid"`
+	Status struct {
+		Confirmed   bool  `json:"confirmed"`
+		BlockHeight int   `json:"block_height"`
+		BlockTime   int64 `json:"block_time"`
+	} `json:"status"`
+	Vout []struct {
+		Address string `json:"scriptpubkey_address"`
+		Value   int64  `json:"value"`
+	} `json:"vout"`
+}
+
+func (w *BitcoinWatcher) tipHeight() (int, error) {
+	resp, err := paymentHTTPClient.Get(strings.TrimRight(w.ExplorerURL, "/") + "/blocks/tip/height")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to get tip height: %s", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(strings.TrimSpace(string(body)))
+}
+
+// Returns the confirmed transactions of the address, newest first, going
+// back page by page until `since`.
+func (w *BitcoinWatcher) transactions(address string, since time.Time) ([]bitcoinTransaction, error) {
+	base := fmt.Sprintf("%s/address/%s/txs/chain", strings.TrimRight(w.ExplorerURL, "/"), url.PathEscape(address))
+	var txs []bitcoinTransaction
+	endpoint := base
+	for {
+		var page []bitcoinTransaction
+		if err := getJSON(endpoint, &page); err != nil {
+			return nil, err
+		}
+		txs = append(txs, page...)
+		if len(page) < esploraPageSize {
+			return txs, nil
+		}
+		last := page[len(page)-1]
+		if time.Unix(last.Status.BlockTime, 0).Before(since) {
+			return txs, nil
+		}
+		endpoint = base + "/" + url.PathEscape(last.TxID)
+	}
+}
+
+func (w *BitcoinWatcher) Payments(address, currency string, since time.Time) ([]Payment, error) {
+	if currency != "BTC" {
+		return nil, fmt.Errorf("unsupported currency for bitcoin explorer: %s", currency)
+	}
+
+	txs, err := w.transactions(address, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions from bitcoin explorer: %v", err)
+	}
+
+	tip, err := w.tipHeight()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bitcoin explorer tip: %v", err)
+	}
+
+	var payments []Payment
+	for _, tx := range txs {
+		at := time.Unix(tx.Status.BlockTime, 0)
+		if !tx.Status.Confirmed || at.Before(since) {
+			continue
+		}
+		var received int64
+		for _, out := range tx.Vout {
+			if out.Address == address {
+				received += out.Value
+			}
+		}
+		if received > 0 {
+			payments = append(payments, Payment{tx.TxID, float64(received) / 1e8, at, tip - tx.Status.BlockHeight + 1})
+		}
+	}
+
+	return payments, nil
+}
+
+// Returns the watchers for each currency that has a payment address and an
+// API configured.
+func newPaymentWatchers(config *PaymentConfig) map[string]PaymentWatcher {
+	watchers := make(map[string]PaymentWatcher)
+	if config.SkycoinNode != "" && config.Addresses["SKY"] != "" {
+		watchers["SKY"] = &SkycoinWatcher{NodeURL: config.SkycoinNode}
+	}
+	if config.BitcoinExplorer != "" && config.Addresses["BTC"] != "" {
+		watchers["BTC"] = &BitcoinWatcher{ExplorerURL: config.BitcoinExplorer}
+	}
+	return watchers
+}
+
+// Finds the payment of the auction. An auction keeps the transaction it got
+// matched with, the others may pay for it unless they pay for another auction
+// already. The smallest payment that covers the bid wins, then the earliest.
+func matchPayment(auction *Auction, payments []Payment, claimed map[string]bool) *Payment {
+	var best *Payment
+	for i := range payments {
+		p := &payments[i]
+		if auction.PaymentTxID != "" {
+			if p.TxID == auction.PaymentTxID {
+				return p
+			}
+			continue
+		}
+		if claimed[p.TxID] || p.Time.Before(auction.EndTime.Time) || p.Amount < auction.BidVal-paymentPrecision {
+			continue
+		}
+		if best == nil || p.Amount < best.Amount-paymentPrecision ||
+			(math.Abs(p.Amount-best.Amount) < paymentPrecision && p.Time.Before(best.Time)) {
+			best = p
+		}
+	}
+	return best
+}
+
+// Checks the payments of all closed auctions that have not been paid yet and
+// updates their settlement state. Every transaction pays for one auction at
+// most, the oldest auctions get matched first.
+func (bot *Bot) checkPayments() {
+	auctions, err := bot.db.GetUnsettledAuctions()
+	if err != nil {
+		log.Printf("failed to get unsettled auctions: %v", err)
+		return
+	}
+	txids, err := bot.db.GetClaimedPayments()
+	if err != nil {
+		log.Printf("failed to get claimed payments: %v", err)
+		return
+	}
+	claimed := make(map[string]bool)
+	for _, txid := range txids {
+		claimed[txid] = true
+	}
+
+	// the payments to each address since the earliest unsettled auction
+	since := make(map[string]time.Time)
+	for _, auction := range auctions {
+		if t, found := since[auction.BidType]; !found || auction.EndTime.Time.Before(t) {
+			since[auction.BidType] = auction.EndTime.Time
+		}
+	}
+	payments := make(map[string][]Payment)
+	for currency, t := range since {
+		watcher, found := bot.paymentWatchers[currency]
+		if !found {
+			continue
+		}
+		list, err := watcher.Payments(bot.config.Payment.Addresses[currency], currency, t)
+		if err != nil {
+			log.Printf("failed to check %s payments: %v", currency, err)
+			continue
+		}
+		payments[currency] = list
+	}
+
+	for _, auction := range auctions {
+		list, found := payments[auction.BidType]
+		if !found {
+			continue
+		}
+
+		// a transaction that is gone does not pay for the auction anymore
+		var confirmations int
+		var txid string
+		if payment := matchPayment(&auction, list, claimed); payment != nil {
+			confirmations, txid = payment.Confirmations, payment.TxID
+			claimed[txid] = true
+		}
+		status := paymentUnpaid
+		if confirmations >= bot.config.Payment.Confirmations[auction.BidType] && confirmations > 0 {
+			status = paymentPaid
+		} else if confirmations > 0 {
+			status = paymentPending
+		}
+
+		if status == auction.PaymentStatus && confirmations == auction.PaymentConfirmations && txid == auction.PaymentTxID {
+			continue
+		}
+
+		if err := bot.db.SetAuctionPayment(auction.ID, status, confirmations, txid); err != nil {
+			log.Printf("failed to save payment of auction %d: %v", auction.ID, err)
+			continue
+		}
+
+		log.Printf("auction %d payment: %s (%d confirmations, %s)", auction.ID, status, confirmations, txid)
+		if status == paymentPaid {
+			bid := Bid{Value: auction.BidVal, CoinType: auction.BidType}
+			bot.notifyAdmins("payment_confirmed", paymentMessage{auction.ID, bid.String()})
+		}
+	}
+}
+
+// Checks payments and gives strikes to those who did not pay in time, also
+// for currencies without a watcher.
+func (bot *Bot) watchPayments() {
+	if len(bot.paymentWatchers) == 0 && bot.config.Strikes.PaymentDeadline.Duration <= 0 {
+		return
+	}
+
+	interval := bot.config.Payment.CheckInterval.Duration
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	for {
+		if len(bot.paymentWatchers) > 0 {
+			bot.checkPayments()
+		}
+		bot.checkUnpaidWins()
+		time.Sleep(interval)
+	}
+}
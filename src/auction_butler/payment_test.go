@@ -0,0 +1,122 @@
+package auction_butler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testBitcoinAddress = "bc1qtest"
+
+// An Esplora explorer with a tip at height 110 and the given transactions of
+// the test address, newest first.
+func newExplorerStub(t *testing.T, txs *[]bitcoinTransaction) *httptest.Server {
+	base := "/address/" + testBitcoinAddress + "/txs/chain"
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/blocks/tip/height":
+			fmt.Fprint(w, "110")
+		case strings.HasPrefix(r.URL.Path, base):
+			txs := *txs
+			// the page after the given transaction
+			from := 0
+			if last := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, base), "/"); last != "" {
+				for i := range txs {
+					if txs[i].TxID == last {
+						from = i + 1
+					}
+				}
+			}
+			to := from + esploraPageSize
+			if to > len(txs) {
+				to = len(txs)
+			}
+			json.NewEncoder(w).Encode(txs[from:to])
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func bitcoinTx(txid string, height int, at time.Time, satoshis int64) bitcoinTransaction {
+	var tx bitcoinTransaction
+	tx.TxID = txid
+	tx.Status.Confirmed = true
+	tx.Status.BlockHeight = height
+	tx.Status.BlockTime = at.Unix()
+	tx.Vout = append(tx.Vout, struct {
+		Address string `json:"scriptpubkey_address"`
+		Value   int64  `json:"value"`
+	}{testBitcoinAddress, satoshis})
+	return tx
+}
+
+func TestBitcoinWatcherPages(t *testing.T) {
+	// the payment is on the second page, behind small unrelated ones
+	txs := []bitcoinTransaction{}
+	for i := 0; i < esploraPageSize; i++ {
+		txs = append(txs, bitcoinTx(fmt.Sprintf("small%d", i), 109, testStart.Add(time.Hour), 1000))
+	}
+	txs = append(txs, bitcoinTx("payment", 100, testStart.Add(time.Minute), 50000000))
+	txs = append(txs, bitcoinTx("old", 90, testStart.Add(-time.Hour), 50000000))
+	server := newExplorerStub(t, &txs)
+	defer server.Close()
+
+	watcher := &BitcoinWatcher{ExplorerURL: server.URL}
+	payments, err := watcher.Payments(testBitcoinAddress, "BTC", testStart)
+	if err != nil {
+		t.Fatalf("failed to get payments: %v", err)
+	}
+	if len(payments) != esploraPageSize+1 {
+		t.Fatalf("got %d payments, want %d", len(payments), esploraPageSize+1)
+	}
+	last := payments[len(payments)-1]
+	if last.TxID != "payment" || last.Amount != 0.5 || last.Confirmations != 11 {
+		t.Errorf("unexpected payment %+v", last)
+	}
+}
+
+// One transaction pays for one auction only, even if it covers the bids of
+// several.
+func TestCheckPayments(t *testing.T) {
+	txs := []bitcoinTransaction{bitcoinTx("first", 100, testStart.Add(time.Hour), 50000000)}
+	server := newExplorerStub(t, &txs)
+	defer server.Close()
+
+	config := testConfig()
+	config.Payment = PaymentConfig{
+		Addresses:       map[string]string{"BTC": testBitcoinAddress},
+		Confirmations:   map[string]int{"BTC": 3},
+		BitcoinExplorer: server.URL,
+	}
+	bot, _, _ := newTestBot(t, config)
+	bot.paymentWatchers = newPaymentWatchers(&config.Payment)
+	for i := 1; i <= 2; i++ {
+		if err := bot.db.PutAuction(testStart); err != nil {
+			t.Fatal(err)
+		}
+		bot.db.SetAuctionBid(i, &Bid{Value: 0.5, CoinType: "BTC"}, 42+i)
+		bot.db.EndAuction(i)
+	}
+
+	bot.checkPayments()
+	first, second := bot.db.GetAuction(1), bot.db.GetAuction(2)
+	if first.PaymentStatus != paymentPaid || first.PaymentTxID != "first" {
+		t.Errorf("first auction: %s by %q", first.PaymentStatus, first.PaymentTxID)
+	}
+	if second.PaymentStatus != paymentUnpaid || second.PaymentTxID != "" {
+		t.Errorf("second auction: %s by %q", second.PaymentStatus, second.PaymentTxID)
+	}
+
+	// a second transaction pays for the second auction
+	txs = append([]bitcoinTransaction{bitcoinTx("second", 109, testStart.Add(2*time.Hour), 50000000)}, txs...)
+	bot.checkPayments()
+	if second = bot.db.GetAuction(2); second.PaymentStatus != paymentPending || second.PaymentTxID != "second" {
+		t.Errorf("second auction: %s by %q", second.PaymentStatus, second.PaymentTxID)
+	}
+}
@@ -0,0 +1,493 @@
+package auction_butler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How far ahead the auctions of recurring auctions are created, and how often
+// to check for new ones
+const (
+	recurringHorizon       = 24 * time.Hour
+	recurringCheckInterval = time.Minute
+)
+
+// Upper bound of auctions created per recurring auction and check, in case of
+// a tiny interval
+const maxRecurringAuctions = 100
+
+// Cron expressions are looked at this far ahead, those without a match, like
+// the 31st of February, are refused
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// How many starts ahead are checked for auctions that would overlap
+const overlapCheckStarts = 10000
+
+// Shortcuts for common cron expressions
+var cronAliases = map[string]string{
+	"@hourly": "0 * * * *",
+	"@daily":  "0 0 * * *",
+	"@weekly": "0 0 * * 0",
+}
+
+// When the auctions of a recurring auction start
+type recurrence interface {
+	// the first start after the given time, zero if there is none
+	next(after time.Time) time.Time
+}
+
+type everySchedule struct {
+	interval time.Duration
+}
+
+func (s everySchedule) next(after time.Time) time.Time {
+	return after.Add(s.interval)
+}
+
+// A cron expression of minute, hour, day of month, month and day of week, in
+// UTC. Each field is a set of allowed values.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// whether the day fields were given as *
+	domAny, dowAny bool
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	// like in cron, restricting both days means either of them
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (s *cronSchedule) next(after time.Time) time.Time {
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Parses one field of a cron expression: a comma separated list of *, values
+// and ranges, each optionally with a step.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step: %s", part)
+			}
+			part = part[:i]
+		}
+
+		from, to := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value: %s", part)
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid range: %s", part)
+				}
+			} else if step > 1 {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return 0, fmt.Errorf("%s is out of range %d-%d", part, min, max)
+		}
+
+		for v := from; v <= to; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func parseCron(expr string) (*cronSchedule, error) {
+	if alias, found := cronAliases[expr]; found {
+		expr = alias
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("a cron expression has 5 fields: minute, hour, day of month, month and day of week")
+	}
+
+	var s cronSchedule
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	// sunday is both 0 and 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domAny = fields[2] == "*"
+	s.dowAny = fields[4] == "*"
+	return &s, nil
+}
+
+// Parses "every [interval]" or a cron expression, which has to match some
+// time after now.
+func parseRecurrence(schedule string, now time.Time) (recurrence, error) {
+	words := strings.Fields(strings.ToLower(schedule))
+	if len(words) > 0 && words[0] == "every" {
+		if len(words) != 2 {
+			return nil, fmt.Errorf("every needs an interval")
+		}
+		interval, err := parseDuration(words[1])
+		if err != nil || interval < time.Minute {
+			return nil, fmt.Errorf("invalid interval: %s, it has to be a minute at least", words[1])
+		}
+		return everySchedule{interval}, nil
+	}
+
+	s, err := parseCron(strings.Join(words, " "))
+	if err != nil {
+		return nil, err
+	}
+	if s.next(now).IsZero() {
+		return nil, fmt.Errorf("%s never matches", schedule)
+	}
+	return s, nil
+}
+
+// Whether an auction of the given duration would still run when the next one
+// starts. The gaps of cron expressions vary, e.g. 0 9,10 * * *, so the starts
+// of a year after now are looked at.
+func overlaps(sched recurrence, duration time.Duration, now time.Time) bool {
+	start := sched.next(now)
+	end := now.AddDate(1, 0, 0)
+	for i := 0; i < overlapCheckStarts && !start.IsZero() && start.Before(end); i++ {
+		next := sched.next(start)
+		if !next.IsZero() && next.Sub(start) < duration {
+			return true
+		}
+		start = next
+	}
+	return false
+}
+
+func isRecurringOption(word string) bool {
+	switch strings.ToLower(word) {
+	case "starting", "lot", "reminders":
+		return true
+	}
+	return false
+}
+
+// Parses "[schedule] for [duration] starting [bid](optional) lot [name](optional)
+// reminders [plan](optional)".
+func parseRecurringArgs(args string, now time.Time) (*RecurringAuction, recurrence, error) {
+	i := strings.Index(strings.ToLower(args), " for ")
+	if i < 0 {
+		return nil, nil, fmt.Errorf("give a schedule and how long the auctions last, e.g. 0 18 * * * for 2h")
+	}
+	r := RecurringAuction{Schedule: strings.Join(strings.Fields(args[:i]), " ")}
+	sched, err := parseRecurrence(r.Schedule, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	words := strings.Fields(args[i+len(" for "):])
+	if len(words) == 0 {
+		return nil, nil, fmt.Errorf("insufficient arguments")
+	}
+	if r.Duration, err = parsePlanDuration(words[0]); err != nil || r.Duration.Duration <= 0 {
+		return nil, nil, fmt.Errorf("invalid duration: %s", words[0])
+	}
+	if overlaps(sched, r.Duration.Duration, now) {
+		return nil, nil, fmt.Errorf("the auctions would overlap, they last longer than the time between two starts")
+	}
+
+	// a lot name runs up to the next option, the reminders plan to the end
+	for rest := words[1:]; len(rest) > 0; {
+		switch strings.ToLower(rest[0]) {
+		case "starting":
+			if len(rest) < 2 {
+				return nil, nil, fmt.Errorf("starting needs a bid, e.g. starting 10 SKY")
+			}
+			n, coinType := 2, ""
+			if len(rest) > 2 && (strings.ToUpper(rest[2]) == "SKY" || strings.ToUpper(rest[2]) == "BTC") {
+				n, coinType = 3, strings.ToUpper(rest[2])
+			}
+			bid := parseBid(rest[1], coinType)
+			if bid == nil || bid.Value <= 0 {
+				return nil, nil, fmt.Errorf("invalid starting bid: %s", strings.Join(rest[1:n], " "))
+			}
+			r.StartBidVal, r.StartBidType = bid.Value, bid.CoinType
+			rest = rest[n:]
+		case "lot":
+			n := 1
+			for n < len(rest) && !isRecurringOption(rest[n]) {
+				n++
+			}
+			if n == 1 {
+				return nil, nil, fmt.Errorf("lot needs a name")
+			}
+			r.Lot = strings.Join(rest[1:n], " ")
+			rest = rest[n:]
+		case "reminders":
+			plan, err := parseReminderPlan(strings.Join(rest[1:], " "))
+			if err != nil {
+				return nil, nil, err
+			}
+			if plan != nil {
+				b, err := json.Marshal(plan)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to encode reminder plan: %v", err)
+				}
+				r.ReminderPlan = string(b)
+			}
+			rest = nil
+		default:
+			return nil, nil, fmt.Errorf("unexpected %s, only starting, lot and reminders may follow the duration", rest[0])
+		}
+	}
+	return &r, sched, nil
+}
+
+// Creates the auctions of recurring auctions that start within the horizon.
+// Those that would have ended already, because the bot was offline, are
+// skipped. Returns how many got created.
+func (bot *Bot) materializeRecurring(now time.Time) (int, error) {
+	// the watcher and the commands may run this at the same time
+	bot.recurringLock.Lock()
+	defer bot.recurringLock.Unlock()
+
+	recurring, err := bot.db.GetRecurringAuctions()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get recurring auctions: %v", err)
+	}
+
+	var created int
+	for _, r := range recurring {
+		if r.Paused {
+			continue
+		}
+		sched, err := parseRecurrence(r.Schedule, now)
+		if err != nil {
+			log.Printf("invalid schedule of recurring auction #%d: %v", r.ID, err)
+			continue
+		}
+
+		start := r.NextStart
+		for !start.IsZero() && !start.Add(r.Duration.Duration).After(now) {
+			start = sched.next(start)
+		}
+		for i := 0; i < maxRecurringAuctions && !start.IsZero() && start.Before(now.Add(recurringHorizon)); i++ {
+			auction := Auction{
+				StartTime:    NewNullTime(start),
+				EndTime:      NewNullTime(start.Add(r.Duration.Duration)),
+				BidVal:       r.StartBidVal,
+				BidType:      r.StartBidType,
+				Lot:          r.Lot,
+				ReminderPlan: r.ReminderPlan,
+				RecurringID:  r.ID,
+			}
+			if err := bot.db.PutScheduledAuction(&auction); err != nil {
+				return created, fmt.Errorf("failed to create auction of recurring auction #%d: %v", r.ID, err)
+			}
+			created++
+			log.Printf("created auction of recurring auction #%d starting %s", r.ID, start.UTC())
+			start = sched.next(start)
+		}
+
+		if !start.Equal(r.NextStart) {
+			if err := bot.db.SetRecurringNextStart(r.ID, start); err != nil {
+				return created, fmt.Errorf("failed to save recurring auction #%d: %v", r.ID, err)
+			}
+		}
+	}
+	return created, nil
+}
+
+func (bot *Bot) watchRecurring() {
+	for {
+		created, err := bot.materializeRecurring(bot.clock.Now())
+		if err != nil {
+			log.Printf("failed to create recurring auctions: %v", err)
+		}
+		if created > 0 {
+			bot.Reschedule()
+		}
+		time.Sleep(recurringCheckInterval)
+	}
+}
+
+func (bot *Bot) getRecurring(args string) (*RecurringAuction, error) {
+	id, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(args), "#"))
+	if err != nil {
+		return nil, fmt.Errorf("give the id of a recurring auction, see /recurring")
+	}
+	r := bot.db.GetRecurringAuction(id)
+	if r == nil {
+		return nil, fmt.Errorf("recurring auction #%d not found", id)
+	}
+	return r, nil
+}
+
+func (bot *Bot) describeRecurring(r *RecurringAuction) recurringLine {
+	line := recurringLine{
+		ID:       r.ID,
+		Schedule: r.Schedule,
+		Duration: niceDuration(r.Duration.Duration),
+		Lot:      r.Lot,
+		Paused:   r.Paused,
+	}
+	if r.StartBidType != "" {
+		bid := Bid{Value: r.StartBidVal, CoinType: r.StartBidType}
+		line.StartBid = bid.String()
+	}
+	if r.ReminderPlan != "" {
+		var plan ReminderPlan
+		if err := json.Unmarshal([]byte(r.ReminderPlan), &plan); err == nil {
+			line.Reminders = plan.String()
+		}
+	}
+	if !r.NextStart.IsZero() {
+		line.Until = niceTime(r.NextStart.UTC())
+	}
+	return line
+}
+
+func (bot *Bot) handleCommandRecurring(ctx *Context, command, args string) error {
+	recurring, err := bot.db.GetRecurringAuctions()
+	if err != nil {
+		return fmt.Errorf("failed to get recurring auctions: %v", err)
+	}
+
+	var msg recurringMessage
+	for i := range recurring {
+		msg.Recurring = append(msg.Recurring, bot.describeRecurring(&recurring[i]))
+	}
+	return bot.replyTemplateLines(ctx, "recurring", msg)
+}
+
+func (bot *Bot) handleCommandAddRecurring(ctx *Context, command, args string) error {
+	r, sched, err := parseRecurringArgs(args, bot.clock.Now())
+	if err != nil {
+		return fmt.Errorf("could not understand: %v", err)
+	}
+	r.NextStart = sched.next(bot.clock.Now())
+	r.CreatedBy = ctx.User.ID
+	if err := bot.db.PutRecurringAuction(r); err != nil {
+		return fmt.Errorf("failed to save recurring auction: %v", err)
+	}
+	log.Printf("recurring auction #%d added by %s: %s", r.ID, ctx.User.NameAndTags(), r.Schedule)
+
+	if _, err := bot.materializeRecurring(bot.clock.Now()); err != nil {
+		log.Printf("failed to create recurring auctions: %v", err)
+	}
+	bot.Reschedule()
+	return bot.ReplyTemplate(ctx, "recurring_added", bot.describeRecurring(bot.db.GetRecurringAuction(r.ID)))
+}
+
+func (bot *Bot) handleCommandPauseRecurring(ctx *Context, command, args string) error {
+	r, err := bot.getRecurring(args)
+	if err != nil {
+		return err
+	}
+	if r.Paused {
+		return fmt.Errorf("recurring auction #%d is paused already", r.ID)
+	}
+
+	bot.recurringLock.Lock()
+	defer bot.recurringLock.Unlock()
+	deleted, err := bot.db.DeleteUpcomingAuctions(r.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete upcoming auctions: %v", err)
+	}
+	// the deleted auctions get created again when resumed in time
+	next := r.NextStart
+	if len(deleted) > 0 {
+		next = deleted[0]
+	}
+	if err := bot.db.SetRecurringPaused(r.ID, true, next); err != nil {
+		return fmt.Errorf("failed to pause recurring auction: %v", err)
+	}
+	return bot.ReplyTemplate(ctx, "recurring_paused", recurringChangeMessage{r.ID, len(deleted)})
+}
+
+func (bot *Bot) handleCommandResumeRecurring(ctx *Context, command, args string) error {
+	r, err := bot.getRecurring(args)
+	if err != nil {
+		return err
+	}
+	if !r.Paused {
+		return fmt.Errorf("recurring auction #%d is not paused", r.ID)
+	}
+	now := bot.clock.Now()
+	sched, err := parseRecurrence(r.Schedule, now)
+	if err != nil {
+		return fmt.Errorf("invalid schedule: %v", err)
+	}
+
+	// auctions missed while paused are not made up for
+	next := r.NextStart
+	for !next.IsZero() && !next.After(now) {
+		next = sched.next(next)
+	}
+	if err := bot.db.SetRecurringPaused(r.ID, false, next); err != nil {
+		return fmt.Errorf("failed to resume recurring auction: %v", err)
+	}
+
+	if _, err := bot.materializeRecurring(now); err != nil {
+		log.Printf("failed to create recurring auctions: %v", err)
+	}
+	bot.Reschedule()
+	return bot.ReplyTemplate(ctx, "recurring_resumed", bot.describeRecurring(bot.db.GetRecurringAuction(r.ID)))
+}
+
+func (bot *Bot) handleCommandDeleteRecurring(ctx *Context, command, args string) error {
+	r, err := bot.getRecurring(args)
+	if err != nil {
+		return err
+	}
+
+	bot.recurringLock.Lock()
+	defer bot.recurringLock.Unlock()
+	deleted, err := bot.db.DeleteUpcomingAuctions(r.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete upcoming auctions: %v", err)
+	}
+	if err := bot.db.DeleteRecurringAuction(r.ID); err != nil {
+		return fmt.Errorf("failed to delete recurring auction: %v", err)
+	}
+	log.Printf("recurring auction #%d deleted by %s", r.ID, ctx.User.NameAndTags())
+	return bot.ReplyTemplate(ctx, "recurring_deleted", recurringChangeMessage{r.ID, len(deleted)})
+}
@@ -0,0 +1,137 @@
+package auction_butler
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	cases := []struct {
+		expr  string
+		after time.Time
+		want  time.Time
+	}{
+		{"0 18 * * *", testStart, time.Date(2018, 5, 1, 18, 0, 0, 0, time.UTC)},
+		{"0 18 * * *", time.Date(2018, 5, 1, 18, 0, 0, 0, time.UTC), time.Date(2018, 5, 2, 18, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", testStart.Add(time.Minute), time.Date(2018, 5, 1, 12, 15, 0, 0, time.UTC)},
+		{"30 9 * * 1-5", time.Date(2018, 5, 4, 10, 0, 0, 0, time.UTC), time.Date(2018, 5, 7, 9, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", testStart, time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 31 12 *", testStart, time.Date(2018, 12, 31, 12, 0, 0, 0, time.UTC)},
+		{"@daily", testStart, time.Date(2018, 5, 2, 0, 0, 0, 0, time.UTC)},
+		// sunday given as 7
+		{"0 8 * * 7", testStart, time.Date(2018, 5, 6, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		s, err := parseCron(c.expr)
+		if err != nil {
+			t.Errorf("failed to parse %q: %v", c.expr, err)
+			continue
+		}
+		if got := s.next(c.after); !got.Equal(c.want) {
+			t.Errorf("%q after %v: got %v, want %v", c.expr, c.after, got, c.want)
+		}
+	}
+}
+
+func TestParseRecurrenceErrors(t *testing.T) {
+	for _, schedule := range []string{
+		"",
+		"0 18 * *",
+		"60 * * * *",
+		"0 0 31 2 *",
+		"every",
+		"every 30s",
+	} {
+		if _, err := parseRecurrence(schedule, testStart); err == nil {
+			t.Errorf("expected an error for %q", schedule)
+		}
+	}
+}
+
+func TestParseRecurringArgs(t *testing.T) {
+	r, sched, err := parseRecurringArgs("every 24h for 2h reminders 1h 10m", testStart)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if r.Schedule != "every 24h" || r.Duration.Duration != 2*time.Hour || r.ReminderPlan == "" {
+		t.Errorf("unexpected recurring auction %+v", r)
+	}
+	if next := sched.next(testStart); !next.Equal(testStart.Add(24 * time.Hour)) {
+		t.Errorf("next start %v", next)
+	}
+
+	for _, args := range []string{"every 1h for 2h", "*/30 * * * * for 2h", "0 9,10 * * * for 90m"} {
+		if _, _, err := parseRecurringArgs(args, testStart); err == nil {
+			t.Errorf("expected an error for overlapping auctions: %s", args)
+		}
+	}
+	if _, _, err := parseRecurringArgs("0 9,12 * * * for 3h", testStart); err != nil {
+		t.Errorf("auctions ending as the next starts should be fine: %v", err)
+	}
+	if _, _, err := parseRecurringArgs("0 18 * * *", testStart); err == nil {
+		t.Error("expected an error without a duration")
+	}
+	if _, _, err := parseRecurringArgs("0 18 * * * for 2h starting nothing", testStart); err == nil {
+		t.Error("expected an error for an invalid starting bid")
+	}
+}
+
+// The lot and the starting bid carry over to the auctions.
+func TestRecurringLot(t *testing.T) {
+	bot, _, _ := newTestBot(t, testConfig())
+	r, sched, err := parseRecurringArgs("0 18 * * * for 2h lot Daily Kitty starting 10 sky reminders 10m", testStart)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if r.Lot != "Daily Kitty" || r.StartBidVal != 10 || r.StartBidType != "SKY" || r.ReminderPlan == "" {
+		t.Fatalf("unexpected recurring auction %+v", r)
+	}
+	r.NextStart = sched.next(testStart)
+	if err := bot.db.PutRecurringAuction(r); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bot.materializeRecurring(testStart); err != nil {
+		t.Fatal(err)
+	}
+
+	auctions, _ := bot.db.GetOpenAuctions()
+	if len(auctions) != 1 {
+		t.Fatalf("created %d auctions, want 1", len(auctions))
+	}
+	if a := auctions[0]; a.Lot != "Daily Kitty" || a.BidVal != 10 || a.BidType != "SKY" || a.BidderID != 0 {
+		t.Errorf("unexpected auction %+v", a)
+	}
+}
+
+// The watcher and the commands create auctions at the same time without
+// creating any twice.
+func TestMaterializeRecurringConcurrently(t *testing.T) {
+	bot, _, _ := newTestBot(t, testConfig())
+	r, sched, err := parseRecurringArgs("every 1h for 30m", testStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.NextStart = sched.next(testStart)
+	if err := bot.db.PutRecurringAuction(r); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := bot.materializeRecurring(testStart); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// one auction for every hour of the horizon after the current one
+	auctions, _ := bot.db.GetOpenAuctions()
+	if want := int(recurringHorizon/time.Hour) - 1; len(auctions) != want {
+		t.Errorf("created %d auctions, want %d", len(auctions), want)
+	}
+}
@@ -0,0 +1,243 @@
+package auction_butler
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Upper bound of reminders per auction, in case of a tiny interval
+const maxReminders = 100
+
+// Something the scheduler does at a given time
+type timelineEvent struct {
+	At   time.Time
+	Task task
+}
+
+func (p *ReminderPlan) empty() bool {
+	return len(p.Offsets) == 0 && p.Decay == nil && p.Every.Duration <= 0
+}
+
+func (p *ReminderPlan) validate() error {
+	for _, offset := range p.Offsets {
+		if offset.Duration <= 0 {
+			return fmt.Errorf("reminder offsets must be positive")
+		}
+	}
+	if d := p.Decay; d != nil {
+		if d.First.Duration <= 0 || d.Min.Duration <= 0 {
+			return fmt.Errorf("the first and the last reminder must be before the end")
+		}
+		if d.Factor <= 0 || d.Factor >= 1 {
+			return fmt.Errorf("the decay factor must be between 0 and 1")
+		}
+	}
+	return nil
+}
+
+func (p *ReminderPlan) String() string {
+	switch {
+	case len(p.Offsets) > 0:
+		var offsets []string
+		for _, offset := range p.Offsets {
+			offsets = append(offsets, niceDuration(offset.Duration))
+		}
+		return strings.Join(offsets, ", ") + " before the end"
+	case p.Decay != nil:
+		return fmt.Sprintf("%s before the end, then whenever the time left shrank by %v, down to %s",
+			niceDuration(p.Decay.First.Duration), p.Decay.Factor, niceDuration(p.Decay.Min.Duration))
+	case p.Every.Duration > 0:
+		return "every " + niceDuration(p.Every.Duration)
+	}
+	return "no reminders"
+}
+
+// Returns how long before the end to remind, longest first. Reminding every
+// so long needs to know how much time is left.
+func (p *ReminderPlan) offsets(left time.Duration) []time.Duration {
+	var offsets []time.Duration
+	switch {
+	case len(p.Offsets) > 0:
+		for _, offset := range p.Offsets {
+			offsets = append(offsets, offset.Duration)
+		}
+	case p.Decay != nil:
+		for offset := p.Decay.First.Duration; offset >= p.Decay.Min.Duration && len(offsets) < maxReminders; offset = time.Duration(float64(offset) * p.Decay.Factor) {
+			offsets = append(offsets, offset)
+		}
+	case p.Every.Duration > 0:
+		for offset := p.Every.Duration; offset < left && len(offsets) < maxReminders; offset += p.Every.Duration {
+			offsets = append(offsets, offset)
+		}
+	}
+
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] > offsets[j] })
+	return offsets
+}
+
+// Parses "[offset]...", "decay [first] [factor] [min]", "every [interval]",
+// or "default", which gives a nil plan.
+func parseReminderPlan(args string) (*ReminderPlan, error) {
+	words := strings.Fields(strings.ToLower(args))
+	if len(words) == 0 {
+		return nil, fmt.Errorf("insufficient arguments")
+	}
+
+	var plan ReminderPlan
+	var err error
+	switch words[0] {
+	case "default":
+		return nil, nil
+	case "decay":
+		if len(words) != 4 {
+			return nil, fmt.Errorf("decay needs the first reminder, a factor and the last reminder")
+		}
+		var decay ReminderDecay
+		if decay.First, err = parsePlanDuration(words[1]); err != nil {
+			return nil, err
+		}
+		if decay.Factor, err = strconv.ParseFloat(words[2], 64); err != nil {
+			return nil, fmt.Errorf("invalid decay factor: %s", words[2])
+		}
+		if decay.Min, err = parsePlanDuration(words[3]); err != nil {
+			return nil, err
+		}
+		plan.Decay = &decay
+	case "every":
+		if len(words) != 2 {
+			return nil, fmt.Errorf("every needs an interval")
+		}
+		if plan.Every, err = parsePlanDuration(words[1]); err != nil {
+			return nil, err
+		}
+	default:
+		for _, word := range words {
+			offset, err := parsePlanDuration(word)
+			if err != nil {
+				return nil, err
+			}
+			plan.Offsets = append(plan.Offsets, offset)
+		}
+	}
+
+	if err := plan.validate(); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// Parses durations like "1h30m", or a number of hours.
+func parsePlanDuration(s string) (Duration, error) {
+	d, err := parseDuration(s)
+	if err != nil {
+		return Duration{}, fmt.Errorf("invalid duration: %s", s)
+	}
+	return NewDuration(d), nil
+}
+
+// Returns the plan of the auction, or the configured one if it has none.
+// Without a configured plan there is a reminder every
+// reminder_announce_interval.
+func (bot *Bot) reminderPlan(auction *Auction) ReminderPlan {
+	if auction.ReminderPlan != "" {
+		var plan ReminderPlan
+		err := json.Unmarshal([]byte(auction.ReminderPlan), &plan)
+		if err == nil {
+			return plan
+		}
+		log.Printf("invalid reminder plan of auction #%d: %v", auction.ID, err)
+	}
+
+	plan := bot.config.Reminders
+	if plan.empty() {
+		plan.Every = bot.config.ReminderAnnounceInterval
+	}
+	return plan
+}
+
+// How long the countdown takes, it starts this long before the end.
+func (bot *Bot) countdownDuration() time.Duration {
+	return bot.countdownLeft(bot.config.CountdownFrom)
+}
+
+// How long a countdown at the given number takes to reach zero, unless it
+// gets reset.
+func (bot *Bot) countdownLeft(count int64) time.Duration {
+	resetting := bot.config.ResettingCountdownFrom
+	if count <= resetting {
+		return time.Duration(count) * resettingCountdownStep
+	}
+	return time.Duration(count-resetting)*countdownStep + time.Duration(resetting)*resettingCountdownStep
+}
+
+// Returns what is left to do for the auction after now: the reminders and
+// then the countdown, which comes last even if it is overdue.
+func (bot *Bot) timeline(auction *Auction, now time.Time) []timelineEvent {
+	end := auction.EndTime.Time
+	countdown := end.Add(-bot.countdownDuration())
+
+	var events []timelineEvent
+	plan := bot.reminderPlan(auction)
+	for _, offset := range plan.offsets(end.Sub(now)) {
+		at := end.Add(-offset)
+		if at.After(now) && at.Before(countdown) {
+			events = append(events, timelineEvent{at, reminderAnnouncement})
+		}
+	}
+
+	return append(events, timelineEvent{countdown, startCountDown})
+}
+
+func (bot *Bot) handleCommandReminders(ctx *Context, command, args string) error {
+	auction := bot.db.GetCurrentAuction()
+	if auction == nil {
+		return fmt.Errorf("no ongoing auction")
+	}
+
+	now := bot.clock.Now()
+	plan := bot.reminderPlan(auction)
+	msg := remindersMessage{
+		ID:      auction.ID,
+		EndTime: bot.userTime(ctx.User, auction.EndTime.Time),
+		Plan:    plan.String(),
+	}
+	for _, event := range bot.timeline(auction, now) {
+		msg.Events = append(msg.Events, reminderLine{
+			Time:      bot.localTime(ctx.User, event.At),
+			In:        niceDuration(event.At.Sub(now)),
+			Countdown: event.Task == startCountDown,
+		})
+	}
+
+	return bot.replyTemplateLines(ctx, "reminders", msg)
+}
+
+func (bot *Bot) handleCommandSetReminders(ctx *Context, command, args string) error {
+	auction := bot.db.GetCurrentAuction()
+	if auction == nil {
+		return fmt.Errorf("no ongoing auction")
+	}
+	plan, err := parseReminderPlan(args)
+	if err != nil {
+		return fmt.Errorf("could not understand: %v", err)
+	}
+
+	var encoded string
+	if plan != nil {
+		b, err := json.Marshal(plan)
+		if err != nil {
+			return fmt.Errorf("failed to encode reminder plan: %v", err)
+		}
+		encoded = string(b)
+	}
+	if err := bot.db.SetAuctionReminderPlan(auction.ID, encoded); err != nil {
+		return fmt.Errorf("failed to save reminder plan: %v", err)
+	}
+
+	bot.Reschedule()
+	return bot.handleCommandReminders(ctx, command, "")
+}
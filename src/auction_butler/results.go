@@ -0,0 +1,167 @@
+package auction_butler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+const defaultResultsPageSize = 5
+
+// More results than this do not fit into one message
+const maxResultsPageSize = 20
+
+func (bot *Bot) winnerName(auction *Auction) string {
+	if auction.BidderID == 0 {
+		return "nobody"
+	}
+	if winner := bot.db.GetUser(auction.BidderID); winner != nil {
+		return winner.Name()
+	}
+	return strconv.Itoa(auction.BidderID)
+}
+
+func (bot *Bot) resultLine(u *User, auction *Auction) resultLine {
+	line := resultLine{
+		ID:      auction.ID,
+		EndTime: bot.localTime(u, auction.EndTime.Time),
+		Payment: auction.PaymentStatus,
+	}
+	if auction.BidderID != 0 {
+		bid := Bid{Value: auction.BidVal, CoinType: auction.BidType}
+		line.Bid = bid.String()
+		line.Winner = bot.winnerName(auction)
+	}
+	return line
+}
+
+// Renders a page of closed auctions together with the keyboard to move
+// between pages.
+func (bot *Bot) resultsPage(u *User, limit, offset int) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	// the paging buttons bring these back, and anyone can make up buttons
+	if limit < 1 {
+		limit = 1
+	} else if limit > maxResultsPageSize {
+		limit = maxResultsPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	results, err := bot.db.GetEndedAuctions(limit, offset)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get auction results: %v", err)
+	}
+	total, err := bot.db.GetEndedAuctionCount()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to count auction results: %v", err)
+	}
+
+	page := resultsMessage{From: offset + 1, To: offset + len(results), Total: total}
+	for i := range results {
+		line := bot.resultLine(u, &results[i].Auction)
+		line.Bids = results[i].BidCount
+		page.Results = append(page.Results, line)
+	}
+	text := bot.text(u, "results", page)
+
+	var buttons []tgbotapi.InlineKeyboardButton
+	if offset > 0 && len(results) > 0 {
+		newer := offset - limit
+		if newer < 0 {
+			newer = 0
+		}
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(bot.text(u, "results_newer", nil), fmt.Sprintf("results:%d:%d", limit, newer)))
+	}
+	if offset+len(results) < total {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(bot.text(u, "results_older", nil), fmt.Sprintf("results:%d:%d", limit, offset+limit)))
+	}
+	if len(buttons) == 0 {
+		return text, nil, nil
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(buttons...))
+	return text, &keyboard, nil
+}
+
+func (bot *Bot) handleCommandResults(ctx *Context, command, args string) error {
+	limit := defaultResultsPageSize
+	if args = strings.TrimSpace(args); args != "" {
+		n, err := strconv.Atoi(args)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid number of results: %s", args)
+		}
+		limit = n
+	}
+
+	text, keyboard, err := bot.resultsPage(ctx.User, limit, 0)
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(ctx.message.Chat.ID, text)
+	msg.ParseMode = "HTML"
+	msg.ReplyToMessageID = ctx.message.MessageID
+	if keyboard != nil {
+		msg.ReplyMarkup = keyboard
+	}
+	_, err = bot.telegram.Send(msg)
+	return err
+}
+
+// Handles the paging buttons of /results, the arguments are "limit:offset".
+func (bot *Bot) handleCallbackResults(ctx *Context, query *tgbotapi.CallbackQuery, args string) error {
+	parts := strings.Split(args, ":")
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid results page: %s", args)
+	}
+	limit, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return fmt.Errorf("invalid results page: %s", args)
+	}
+	offset, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return fmt.Errorf("invalid results page: %s", args)
+	}
+
+	text, keyboard, err := bot.resultsPage(ctx.User, limit, offset)
+	if err != nil {
+		return err
+	}
+
+	edit := tgbotapi.NewEditMessageText(ctx.message.Chat.ID, ctx.message.MessageID, text)
+	edit.ParseMode = "HTML"
+	edit.ReplyMarkup = keyboard
+	_, err = bot.telegram.Send(edit)
+	return err
+}
+
+func (bot *Bot) handleCommandAuction(ctx *Context, command, args string) error {
+	id, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(args), "#"))
+	if err != nil {
+		return fmt.Errorf("usage: /auction [id]")
+	}
+
+	result := bot.db.GetAuctionResult(id)
+	if result == nil {
+		return fmt.Errorf("auction not found: %d", id)
+	}
+
+	msg := auctionMessage{
+		ID:      result.ID,
+		Ended:   result.Ended,
+		EndTime: bot.localTime(ctx.User, result.EndTime.Time),
+		Payment: result.PaymentStatus,
+		Bids:    result.BidCount,
+		Bidders: result.BidderCount,
+	}
+	if result.BidderID != 0 {
+		bid := Bid{Value: result.BidVal, CoinType: result.BidType}
+		msg.Bid = bid.String()
+		msg.Converted = bid.Convert(bot.config.ConversionFactor)
+		msg.Winner = bot.winnerName(&result.Auction)
+	}
+
+	return bot.ReplyTemplate(ctx, "auction", msg)
+}
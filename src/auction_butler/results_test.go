@@ -0,0 +1,38 @@
+package auction_butler
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+// Made up paging buttons get a page within bounds rather than an error.
+func TestResultsPageBounds(t *testing.T) {
+	bot, _, telegram := newTestBot(t, testConfig())
+	for i := 0; i < 3; i++ {
+		if err := bot.db.PutAuction(testStart); err != nil {
+			t.Fatal(err)
+		}
+		bot.db.EndAuction(i + 1)
+	}
+	ctx := &Context{
+		User:    &User{ID: 1, UserName: "alice"},
+		message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 1, Type: "private"}},
+	}
+
+	for _, args := range []string{"-5:0", "0:-10", "1000:0"} {
+		if err := bot.handleCallbackResults(ctx, nil, args); err != nil {
+			t.Errorf("results page %s failed: %v", args, err)
+		}
+	}
+	var edits int
+	for _, call := range telegram.Calls() {
+		if strings.HasPrefix(call, "editMessageText") {
+			edits++
+		}
+	}
+	if edits != 3 {
+		t.Errorf("showed %d pages, want 3", edits)
+	}
+}
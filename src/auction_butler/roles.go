@@ -0,0 +1,157 @@
+package auction_butler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Permissions commands can require
+const (
+	permView     = "view"     // look at auctions and results
+	permBid      = "bid"      // bid and look at one's own bids
+	permAuction  = "auction"  // manage auctions
+	permModerate = "moderate" // strike, ban and look up users
+	permManage   = "manage"   // give and take roles
+)
+
+// Roles users can have
+const (
+	roleOwner      = "owner"
+	roleAdmin      = "admin"
+	roleAuctioneer = "auctioneer"
+	roleModerator  = "moderator"
+	roleBidder     = "bidder"
+	roleViewer     = "viewer"
+)
+
+var rolePermissions = map[string][]string{
+	roleOwner:      {permView, permBid, permAuction, permModerate, permManage},
+	roleAdmin:      {permView, permBid, permAuction, permModerate, permManage},
+	roleAuctioneer: {permView, permBid, permAuction},
+	roleModerator:  {permView, permBid, permModerate},
+	roleBidder:     {permView, permBid},
+	roleViewer:     {permView},
+}
+
+// Users may give roles up to their own, except owner, and change the roles
+// of users ranked below them
+var roleRanks = map[string]int{
+	roleOwner:      4,
+	roleAdmin:      3,
+	roleAuctioneer: 2,
+	roleModerator:  2,
+	roleBidder:     1,
+	roleViewer:     0,
+}
+
+func (u *User) role() string {
+	if u.Role == "" {
+		return roleBidder
+	}
+	return u.Role
+}
+
+// Tells whether the user has the permission. Banned users have none.
+func (u *User) Can(permission string) bool {
+	if u.Banned {
+		return false
+	}
+	for _, p := range rolePermissions[u.role()] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *User) IsAdmin() bool {
+	return u.role() == roleOwner || u.role() == roleAdmin
+}
+
+// Tells whether the user helps running the group, which lets them use
+// commands there and post messages that are not bids.
+func (u *User) IsStaff() bool {
+	return u.Can(permAuction) || u.Can(permModerate) || u.Can(permManage)
+}
+
+func (u *User) outranks(role string) bool {
+	return roleRanks[u.role()] > roleRanks[role]
+}
+
+// Tells whether the user may give the role, e.g. admins may make admins.
+// Only the creator of the group is an owner.
+func (u *User) mayGive(role string) bool {
+	return u.outranks(role) || (role == u.role() && role != roleOwner)
+}
+
+// Gives the role to the user and records who did it. A nil actor means the
+// admin sync did it, which may then take the role back.
+func (bot *Bot) changeRole(actor, user *User, role, reason string) error {
+	action := auditPromote
+	if roleRanks[role] < roleRanks[user.role()] {
+		action = auditDemote
+	}
+
+	user.Role = role
+	user.RoleSynced = actor == nil
+	user.RoleManual = actor != nil
+	if err := bot.db.PutUser(user); err != nil {
+		return fmt.Errorf("failed to change user role: %v", err)
+	}
+
+	log.Printf("role changed: %s", user.NameAndTags())
+	bot.audit(actor, user.ID, action, strings.TrimSpace(role+" "+reason), "")
+	return nil
+}
+
+func (bot *Bot) setRole(ctx *Context, args string, role string) error {
+	user, words, err := bot.targetUser(ctx, args)
+	if err != nil {
+		return err
+	}
+	if role == "" {
+		if len(words) == 0 {
+			return fmt.Errorf("insufficient arguments: give a role")
+		}
+		role, words = strings.ToLower(words[0]), words[1:]
+	}
+	if _, found := rolePermissions[role]; !found {
+		return fmt.Errorf("unknown role: %s", role)
+	}
+	if user.role() == role {
+		return bot.ReplyTemplate(ctx, "no_action", nil)
+	}
+	if !ctx.User.outranks(user.role()) || !ctx.User.mayGive(role) {
+		return fmt.Errorf("you may only change the roles of users below %s, and not to a role above yours", ctx.User.role())
+	}
+
+	if err := bot.changeRole(ctx.User, user, role, strings.Join(words, " ")); err != nil {
+		return err
+	}
+	return bot.ReplyTemplate(ctx, "role_set", roleMessage{user.Name(), role})
+}
+
+func (bot *Bot) handleCommandRole(ctx *Context, command, args string) error {
+	return bot.setRole(ctx, args, "")
+}
+
+func (bot *Bot) handleCommandPromote(ctx *Context, command, args string) error {
+	return bot.setRole(ctx, args, roleAdmin)
+}
+
+func (bot *Bot) handleCommandDemote(ctx *Context, command, args string) error {
+	return bot.setRole(ctx, args, roleBidder)
+}
+
+func (bot *Bot) handleCommandRoles(ctx *Context, command, args string) error {
+	users, err := bot.db.GetUsersWithRoles()
+	if err != nil {
+		return fmt.Errorf("failed to get users: %v", err)
+	}
+
+	var msg rolesMessage
+	for _, user := range users {
+		msg.Users = append(msg.Users, roleLine{user.Role, user.ID, user.Name()})
+	}
+	return bot.replyTemplateLines(ctx, "roles", msg)
+}
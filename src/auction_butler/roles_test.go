@@ -0,0 +1,43 @@
+package auction_butler
+
+import (
+	"testing"
+
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+// Admins may make admins but not owners, and may not demote each other.
+func TestSetRole(t *testing.T) {
+	bot, _, _ := newTestBot(t, testConfig())
+	users := []*User{
+		{ID: 1, UserName: "admin", Role: roleAdmin},
+		{ID: 2, UserName: "other", Role: roleAdmin},
+		{ID: 3, UserName: "alice"},
+		{ID: 4, UserName: "bob"},
+	}
+	for _, u := range users {
+		if err := bot.db.PutUser(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := &Context{
+		User:    bot.db.GetUser(1),
+		message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 1, Type: "private"}},
+	}
+
+	if err := bot.handleCommandPromote(ctx, "promote", "@alice"); err != nil {
+		t.Errorf("admin failed to promote: %v", err)
+	}
+	if err := bot.handleCommandDemote(ctx, "demote", "@other"); err == nil {
+		t.Error("admin demoted another admin")
+	}
+	if err := bot.handleCommandRole(ctx, "role", "@bob owner"); err == nil {
+		t.Error("admin made an owner")
+	}
+
+	for id, want := range map[int]string{2: roleAdmin, 3: roleAdmin, 4: roleBidder} {
+		if got := bot.db.GetUser(id).role(); got != want {
+			t.Errorf("user %d is %s, want %s", id, got, want)
+		}
+	}
+}
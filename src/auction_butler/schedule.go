@@ -3,6 +3,8 @@ package auction_butler
 import (
 	"fmt"
 	"time"
+
+	"gopkg.in/telegram-bot-api.v4"
 )
 
 type task int
@@ -14,141 +16,218 @@ const (
 	startCountDown
 )
 
-// Returns what to do next (start, stop or nothing) and when
-func (bot *Bot) schedule() (task, time.Time) {
-	if bot.runningCountDown {
-		return nothing, time.Time{}
-	}
+// Auction phases, stored so that the engine can go on after a restart
+const (
+	auctionOpen      = "open"
+	auctionCountdown = "countdown"
+	auctionPaused    = "paused"
+	auctionEnded     = "ended"
+	auctionCancelled = "cancelled"
+)
+
+// Pauses between the numbers of the countdown, before and after it starts
+// resetting on bids
+const (
+	countdownStep          = 4 * time.Second
+	resettingCountdownStep = 2 * time.Second
+)
+
+// How long to sleep when there is nothing to do
+const idleInterval = 10 * time.Second
+
+// Returns the next task of the current auction's timeline and when to do it
+func (bot *Bot) subSchedule() (task, time.Time) {
 	auction := bot.db.GetCurrentAuction()
-	if auction == nil {
-		return nothing, time.Time{}
+	if auction == nil || !auction.EndTime.Valid {
+		return nothing, bot.clock.Now().Add(idleInterval)
 	}
 
-	if auction.EndTime.Valid {
-		bot.auctionEndTime = auction.EndTime.Time
-		return endAuction, auction.EndTime.Time.Add(time.Second*-200)
+	if auction.Phase == auctionPaused {
+		return nothing, bot.clock.Now().Add(idleInterval)
 	}
 
-	return nothing, time.Time{}
+	bot.auctionEndTime = auction.EndTime.Time
+	// a countdown interrupted by a restart goes on right away
+	if auction.Phase == auctionCountdown {
+		return startCountDown, bot.clock.Now()
+	}
+	next := bot.timeline(auction, bot.clock.Now())[0]
+	return next.Task, next.At
+}
 
+// Does the task of the current auction. Starting the countdown gives the
+// countdown to run.
+func (bot *Bot) perform(tsk task) *countdown {
+	event := bot.db.GetCurrentAuction()
+	if event == nil {
+		log.Print("failed to perform the scheduled task: no current auction")
+		return nil
+	}
+
+	switch tsk {
+	case reminderAnnouncement:
+		text := bot.text(nil, "auction_ends", endTimeMessage{bot.groupTime(bot.auctionEndTime)})
+		if err := bot.enqueue(jobReminder, reminderJob{event.ID, text}, bot.clock.Now()); err != nil {
+			log.Print(err)
+		}
+	case startCountDown:
+		if event.Phase != auctionOpen && event.Phase != auctionCountdown {
+			return nil
+		}
+		from := bot.config.CountdownFrom
+		if event.Phase == auctionCountdown && event.Countdown > 0 {
+			from = event.Countdown
+		}
+		return bot.startCountdown(event.ID, from)
+	default:
+		log.Printf("unsupported task to perform: %v", tsk)
+	}
+	return nil
 }
 
-// Returns a more detailed version than `schedule()`
-// of what to do next (including announcements).
-func (bot *Bot) subSchedule() (task, time.Time) {
-	fmt.Println(bot.runningCountDown)
-	if bot.runningCountDown {
-		return nothing, time.Now().Add(time.Second * 10)
+// Announces the winner of the auction, if anybody bid, and marks it as ended.
+func (bot *Bot) closeAuction(id int) error {
+	// reload, bids may have come in during the countdown
+	auction := bot.db.GetAuction(id)
+	if auction == nil {
+		return fmt.Errorf("auction #%d not found", id)
 	}
-	tsk, future := bot.schedule()
-	if tsk == nothing {
-		return nothing, time.Now().Add(time.Second * 10)
+	if auction.Ended {
+		return nil
 	}
 
-	// at what intervals to send the reminder for time left
-	//TODO (therealssj): decrease reminder announce interval overtime
-	every := bot.config.ReminderAnnounceInterval.Duration
-
-	announcements := time.Until(future) / every
-	if announcements <= 0 {
-		fmt.Println("this reminder")
-		future := time.Until(future)
-		if tsk == endAuction && future < time.Duration(time.Second*300)  && future > time.Duration(time.Second * 180) {
-			// make a reminder announcement after 2 minutes
-			return reminderAnnouncement, time.Now().Add(2 * time.Minute)
+	last := bot.swapLastBidMessage(nil)
+	if auction.BidderID != 0 {
+		bid := Bid{Value: auction.BidVal, CoinType: auction.BidType}
+		winner := winnerMessage{Winner: bot.userName(auction.BidderID), Bid: bid.String(), Lot: auction.Lot}
+		if last != nil {
+			bot.ReplyTemplate(last, "winner", winner)
+		} else {
+			bot.SendTemplate(&Context{}, "yell", "winner", winner)
 		}
 	}
 
-	if tsk == endAuction && time.Until(future) < time.Duration(time.Second*103) {
-		bot.runningCountDown = true
-		// start countdown if there is almost 100 seconds left till the end
-		return startCountDown, time.Time{}
+	if err := bot.db.EndAuction(id); err != nil {
+		return fmt.Errorf("failed to end auction: %v", err)
 	}
 
-	nearFuture := future.Add(-announcements * every)
-	fmt.Println(nearFuture)
-	fmt.Println("no, this reminder")
-	switch tsk {
-	case endAuction:
-		// make a reminder announcement soon
-		return reminderAnnouncement, nearFuture
-	default:
-		log.Print("unsupported task to subSchedule")
-		return nothing, time.Time{}
-	}
+	log.Printf("auction #%d ended", id)
+	return nil
 }
 
-func (bot *Bot) perform(tsk task) {
-	event := bot.db.GetCurrentAuction()
-	if event == nil {
-		log.Print("failed to perform the scheduled task: no current auction")
-		return
+// Picks up where the engine left off before a restart. Auctions that ran out
+// while the bot was offline get their winner announced. The current one gets
+// its bid message back, and an interrupted countdown is resumed by the
+// scheduler.
+func (bot *Bot) recoverAuctions() error {
+	auctions, err := bot.db.GetOpenAuctions()
+	if err != nil {
+		return fmt.Errorf("failed to get open auctions: %v", err)
 	}
 
-	noctx := &Context{}
-	switch tsk {
-	case reminderAnnouncement:
-		bot.Send(noctx, "yell", "html", fmt.Sprintf(`Auction ends @%s`, niceTime(bot.auctionEndTime.UTC())))
-	case startCountDown:
-		for i := bot.config.CountdownFrom; i>bot.config.ResettingCountdownFrom; i-- {
-			bot.Send(noctx, "yell", "text", fmt.Sprintf("%v", i))
-			time.Sleep(time.Second * 4)
+	for i := range auctions {
+		auction := &auctions[i]
+		// the countdown goes on with a new message, if it goes on at all
+		if auction.CountdownMessageID != 0 {
+			log.Printf("removing the countdown message of auction #%d", auction.ID)
+			bot.removeCountdownMessage(auction.ID, auction.CountdownMessageID, auction.PreviousPinID)
+		}
+		// auctions of recurring auctions that have not started wait
+		if auction.StartTime.Valid && auction.StartTime.Time.After(bot.clock.Now()) {
+			continue
+		}
+		bot.restoreLastBid(auction)
+		// the clock of paused auctions does not run
+		if !auction.EndTime.Valid || auction.Phase == auctionPaused {
+			continue
+		}
+
+		// a countdown may run past the end time if it got reset by bids
+		deadline := auction.EndTime.Time
+		if auction.Phase == auctionCountdown {
+			deadline = deadline.Add(bot.countdownDuration())
 		}
-		for i := bot.config.ResettingCountdownFrom; i > 0; i-- {
-			select {
-			// if a bid was placed reset the counter
-			case <-bot.bidChan:
-				if i > 8 {
-					i = 8
-				} else {
-					bot.Send(noctx, "yell", "text", fmt.Sprintf("%v", i))
-					time.Sleep(time.Second * 2)
-				}
-			default:
-				bot.Send(noctx, "yell", "text", fmt.Sprintf("%v", i))
-				time.Sleep(time.Second * 2)
+		if bot.clock.Now().After(deadline) {
+			log.Printf("auction #%d ran out while offline", auction.ID)
+			if err := bot.closeAuction(auction.ID); err != nil {
+				return err
 			}
+		} else if auction.Phase == auctionCountdown {
+			log.Printf("resuming the countdown of auction #%d at %d", auction.ID, auction.Countdown)
 		}
+	}
 
-		bot.Reply(bot.lastBidMessage, `Please PM @erichkaestner`)
-		bot.runningCountDown = false
-		fmt.Println(bot.db.EndAuction())
-	default:
-		log.Printf("unsupported task to perform: %v", tsk)
+	return nil
+}
+
+func (bot *Bot) restoreLastBid(auction *Auction) {
+	if auction.MessageID == 0 {
+		bot.swapLastBidMessage(nil)
+		return
 	}
+	bot.swapLastBidMessage(&Context{
+		message: &tgbotapi.Message{
+			MessageID: auction.MessageID,
+			Chat:      &tgbotapi.Chat{ID: bot.config.ChatID},
+		},
+		User: bot.db.GetUser(auction.BidderID),
+	})
 }
 
+// Runs the timeline of the current auction and its countdown. Nothing in
+// here sleeps, so reschedules and bids are picked up at any time.
 func (bot *Bot) maintain() {
-	bot.rescheduleChan = make(chan int)
 	defer func() {
 		close(bot.rescheduleChan)
 	}()
 
-	bot.bidChan = make(chan int, 200)
-	var timer *time.Timer
+	timer := bot.clock.NewTimer(idleInterval)
+	var cd *countdown
 	for {
-
-		tsk, future := bot.subSchedule()
-
-		fmt.Println(tsk)
-		fmt.Println(future)
-		if timer == nil {
-			timer = time.NewTimer(time.Until(future))
+		// the timeline waits while the countdown runs
+		var scheduled <-chan time.Time
+		var tsk task
+		var tick <-chan time.Time
+		if cd == nil {
+			var future time.Time
+			tsk, future = bot.subSchedule()
+			resetTimer(timer, future.Sub(bot.clock.Now()))
+			scheduled = timer.C()
 		} else {
-			timer.Reset(time.Until(future))
+			tick = cd.timer.C()
 		}
 
 		select {
-		case <-timer.C:
-			bot.perform(tsk)
+		case <-scheduled:
+			cd = bot.perform(tsk)
+		case <-tick:
+			cd = bot.tick(cd)
+		case id, ok := <-bot.bidChan:
+			if !ok {
+				bot.stopCountdown(cd)
+				return
+			}
+			if cd != nil && cd.auctionID == id {
+				cd.reset(bot.config.ResettingCountdownFrom)
+			}
 		case <-bot.rescheduleChan:
-			if !timer.Stop() {
-				<-timer.C
+			if cd != nil {
+				cd = bot.checkCountdown(cd)
 			}
 		}
+	}
+}
 
-		fmt.Println("finally")
+// Stops the timer and makes sure that a tick it fired already is not seen
+// after the reset.
+func resetTimer(timer Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C():
+		default:
+		}
 	}
+	timer.Reset(d)
 }
 
 // Cause a reschedule to happen. Call this if you modify events, so that the
@@ -0,0 +1,313 @@
+package auction_butler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+var testStart = time.Date(2018, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func testConfig() Config {
+	return Config{
+		ChatID:                 -100,
+		Language:               "en",
+		CountdownFrom:          5,
+		ResettingCountdownFrom: 3,
+		Reminders: ReminderPlan{
+			Offsets: []Duration{NewDuration(time.Hour), NewDuration(30 * time.Minute)},
+		},
+	}
+}
+
+// Answers telegram requests, remembering the text of every sent message.
+type fakeTelegram struct {
+	mu     sync.Mutex
+	sent   []string
+	lastID int
+	// the methods called, with the message id if there is one
+	calls []string
+	// the group's administrators
+	admins []tgbotapi.ChatMember
+	// the messages deleted, deleting one again fails like telegram does
+	deleted map[string]bool
+}
+
+func (f *fakeTelegram) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	params, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var result interface{} = true
+	response := map[string]interface{}{"ok": true}
+	f.calls = append(f.calls, strings.TrimSpace(path.Base(req.URL.Path)+" "+params.Get("message_id")))
+	switch path.Base(req.URL.Path) {
+	case "deleteMessage":
+		if f.deleted == nil {
+			f.deleted = make(map[string]bool)
+		}
+		if f.deleted[params.Get("message_id")] {
+			response = map[string]interface{}{"ok": false, "description": "Bad Request: message to delete not found"}
+		}
+		f.deleted[params.Get("message_id")] = true
+	case "sendMessage", "editMessageText":
+		f.lastID++
+		if path.Base(req.URL.Path) == "sendMessage" {
+			f.sent = append(f.sent, params.Get("text"))
+		}
+		result = tgbotapi.Message{MessageID: f.lastID, Chat: &tgbotapi.Chat{ID: -100}}
+	case "getChat":
+		result = tgbotapi.Chat{ID: -100, Type: "supergroup"}
+	case "getChatAdministrators":
+		result = f.admins
+	}
+
+	if response["ok"] == true {
+		response["result"] = result
+	}
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(strings.NewReader(string(encoded))),
+	}, nil
+}
+
+func (f *fakeTelegram) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+func (f *fakeTelegram) Sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.sent...)
+}
+
+// Returns a bot that talks to a fake telegram, keeps its data in memory and
+// runs on a fake clock.
+func newTestBot(t *testing.T, config Config) (*Bot, *fakeClock, *fakeTelegram) {
+	clock := newFakeClock(testStart)
+	bot := &Bot{
+		config:         &config,
+		db:             newMemStore(clock),
+		rescheduleChan: make(chan int),
+		bidChan:        make(chan int, 200),
+		chatViolations: make(map[int]int),
+		clock:          clock,
+	}
+	var err error
+	if bot.messages, err = LoadCatalog(&config); err != nil {
+		t.Fatalf("failed to load messages: %v", err)
+	}
+	fake := &fakeTelegram{}
+	bot.telegram = &tgbotapi.BotAPI{Token: "test", Client: &http.Client{Transport: fake}}
+	return bot, clock, fake
+}
+
+func TestTimeline(t *testing.T) {
+	bot := &Bot{config: &Config{CountdownFrom: 5, ResettingCountdownFrom: 3}}
+	bot.config.Reminders = testConfig().Reminders
+	end := testStart.Add(2 * time.Hour)
+	auction := &Auction{EndTime: NewNullTime(end)}
+
+	want := []timelineEvent{
+		{end.Add(-time.Hour), reminderAnnouncement},
+		{end.Add(-30 * time.Minute), reminderAnnouncement},
+		{end.Add(-14 * time.Second), startCountDown},
+	}
+	got := bot.timeline(auction, testStart)
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !got[i].At.Equal(want[i].At) || got[i].Task != want[i].Task {
+			t.Errorf("event %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	// reminders that are due are done already
+	got = bot.timeline(auction, end.Add(-time.Hour))
+	if len(got) != 2 || !got[0].At.Equal(end.Add(-30*time.Minute)) {
+		t.Errorf("got %v after the first reminder", got)
+	}
+}
+
+func TestCountdownLeft(t *testing.T) {
+	bot := &Bot{config: &Config{CountdownFrom: 5, ResettingCountdownFrom: 3}}
+	cases := []struct {
+		count int64
+		want  time.Duration
+	}{
+		{5, 14 * time.Second},
+		{4, 10 * time.Second},
+		{3, 6 * time.Second},
+		{1, 2 * time.Second},
+		{0, 0},
+	}
+	for _, c := range cases {
+		if got := bot.countdownLeft(c.count); got != c.want {
+			t.Errorf("countdownLeft(%d) = %v, want %v", c.count, got, c.want)
+		}
+	}
+	if got := bot.countdownDuration(); got != 14*time.Second {
+		t.Errorf("countdownDuration() = %v, want 14s", got)
+	}
+}
+
+func TestParseStartAuctionArgs(t *testing.T) {
+	later, err := parseStartAuctioArgs("18:00", testStart, time.UTC)
+	if err != nil {
+		t.Fatalf("failed to parse a later time: %v", err)
+	}
+	if want := time.Date(2018, 5, 1, 18, 0, 0, 0, time.UTC); !later.Equal(want) {
+		t.Errorf("got %v, want %v", later, want)
+	}
+
+	// a time of day that passed already means tomorrow
+	earlier, err := parseStartAuctioArgs("10:00", testStart, time.UTC)
+	if err != nil {
+		t.Fatalf("failed to parse an earlier time: %v", err)
+	}
+	if want := time.Date(2018, 5, 2, 10, 0, 0, 0, time.UTC); !earlier.Equal(want) {
+		t.Errorf("got %v, want %v", earlier, want)
+	}
+
+	if _, err := parseStartAuctioArgs("2018-04-30 10:00", testStart, time.UTC); err == nil {
+		t.Error("expected an error for a date in the past")
+	}
+}
+
+// Runs an auction from its first reminder to the winner, with a bid that
+// resets the countdown, without waiting for any of it.
+func TestAuction(t *testing.T) {
+	bot, clock, telegram := newTestBot(t, testConfig())
+	end := testStart.Add(2 * time.Hour)
+	if err := bot.db.PutAuction(end); err != nil {
+		t.Fatalf("failed to create auction: %v", err)
+	}
+	auction := bot.db.GetCurrentAuction()
+	bidder := &User{ID: 42, UserName: "alice", FirstName: "Alice"}
+	if err := bot.db.PutUser(bidder); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		bot.maintain()
+		close(done)
+	}()
+	defer func() {
+		close(bot.bidChan)
+		<-done
+	}()
+
+	// checks when the engine wants to wake up next, and lets it
+	next := func(want time.Time) {
+		t.Helper()
+		if at := clock.WaitArmed(t); !at.Equal(want) {
+			t.Fatalf("engine waits until %v, want %v", at, want)
+		}
+	}
+	advance := func(want time.Time) {
+		t.Helper()
+		next(want)
+		clock.AdvanceToNext()
+	}
+
+	// the reminders are queued, the test runs the queue in place of the worker
+	remind := func(want time.Time) {
+		t.Helper()
+		next(want)
+		if err := bot.runDueJobs(clock.Now()); err != nil {
+			t.Fatalf("failed to run jobs: %v", err)
+		}
+		clock.AdvanceToNext()
+	}
+
+	next(testStart.Add(idleInterval))
+	advance(end.Add(-time.Hour))
+	remind(end.Add(-30 * time.Minute))
+	remind(end.Add(-14 * time.Second))
+	next(end.Add(-14 * time.Second)) // the countdown starts right away
+	advance(end.Add(-10 * time.Second))
+	advance(end.Add(-6 * time.Second))
+	advance(end.Add(-4 * time.Second))
+	next(end.Add(-2 * time.Second))
+
+	// a bid before the last number puts the countdown back
+	bid := Bid{Value: 1.5, CoinType: "SKY"}
+	if err := bot.db.SetAuctionBid(auction.ID, &bid, bidder.ID); err != nil {
+		t.Fatalf("failed to bid: %v", err)
+	}
+	bot.bidChan <- auction.ID
+	advance(end.Add(-2 * time.Second))
+	advance(end)
+	advance(end.Add(2 * time.Second))
+	advance(end.Add(4 * time.Second))
+	next(end.Add(4*time.Second + idleInterval))
+
+	reminder := "Auction ends @" + niceTime(end)
+	want := []string{reminder, reminder, "5", "4", "3", "2", "3", "2", "1"}
+	sent := telegram.Sent()
+	if len(sent) != len(want)+1 {
+		t.Fatalf("sent %q, want %q and the winner", sent, want)
+	}
+	for i := range want {
+		if sent[i] != want[i] {
+			t.Errorf("message %d is %q, want %q", i, sent[i], want[i])
+		}
+	}
+	if winner := sent[len(want)]; !strings.Contains(winner, "won with "+bid.String()) {
+		t.Errorf("unexpected winner message %q", winner)
+	}
+
+	closed := bot.db.GetAuction(auction.ID)
+	if !closed.Ended || closed.Phase != auctionEnded || closed.BidderID != bidder.ID {
+		t.Errorf("auction not closed properly: ended %v, phase %s, bidder %d", closed.Ended, closed.Phase, closed.BidderID)
+	}
+}
+
+// A live countdown message left pinned by a restart is removed, and the
+// message pinned before it is pinned again.
+func TestRecoverCountdownMessage(t *testing.T) {
+	config := testConfig()
+	config.LiveCountdown = true
+	bot, _, telegram := newTestBot(t, config)
+	if err := bot.db.PutAuction(testStart.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	bot.db.SetAuctionPhase(1, auctionCountdown, 3)
+	bot.db.SetAuctionCountdownMessage(1, 77, 5)
+
+	if err := bot.recoverAuctions(); err != nil {
+		t.Fatalf("failed to recover: %v", err)
+	}
+	want := []string{"unpinChatMessage", "deleteMessage 77", "pinChatMessage 5"}
+	calls := telegram.Calls()
+	if strings.Join(calls, ", ") != strings.Join(want, ", ") {
+		t.Errorf("called %q, want %q", calls, want)
+	}
+	if a := bot.db.GetAuction(1); a.CountdownMessageID != 0 || a.PreviousPinID != 0 {
+		t.Errorf("countdown message still stored: %d, %d", a.CountdownMessageID, a.PreviousPinID)
+	}
+}
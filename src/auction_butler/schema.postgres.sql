@@ -7,7 +7,83 @@ CREATE TABLE botuser (
   last_name  TEXT,
   enlisted   BOOL            NOT NULL DEFAULT TRUE, -- is in the group
   banned     BOOL            NOT NULL DEFAULT FALSE, -- is disabled even if in the group
-  admin      BOOL            NOT NULL DEFAULT FALSE  -- can issue commands
+  banned_by_strikes BOOL     NOT NULL DEFAULT FALSE, -- the ban came from strikes, not from /ban
+  role       TEXT            NOT NULL DEFAULT 'bidder', -- owner, admin, auctioneer, moderator, bidder or viewer
+  role_synced BOOL           NOT NULL DEFAULT FALSE, -- the role comes from the group's administrators
+  role_manual BOOL           NOT NULL DEFAULT FALSE, -- the role was given by hand, the sync keeps it
+  suspended_until TIMESTAMP WITH TIME ZONE, -- may not bid until then
+  language   TEXT            NOT NULL DEFAULT '', -- preferred language code, empty for the group default
+  timezone   TEXT            NOT NULL DEFAULT '', -- time zone to show times in, empty for the group's
+  joined_at  TIMESTAMP WITH TIME ZONE -- first time the user joined the group, if the bot saw it
+);
+
+-- Joins and leaves of the group, including those found out after the bot was
+-- offline.
+CREATE TABLE membership_event (
+  id         SERIAL PRIMARY KEY,
+  user_id    INT         NOT NULL,
+  event      TEXT        NOT NULL, -- join, leave or kick
+  actor_id   INT         NOT NULL DEFAULT 0, -- who added or kicked the user, 0 if they did it themselves
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+CREATE INDEX membership_event_user_id ON membership_event (user_id);
+
+-- Usernames users had before they renamed themselves, so admins can recognize
+-- bidders under their old handles.
+CREATE TABLE username_history (
+  id         SERIAL PRIMARY KEY,
+  user_id    INT         NOT NULL REFERENCES botuser(id),
+  username   TEXT        NOT NULL, -- the previous username
+  changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+CREATE INDEX username_history_user_id ON username_history (user_id);
+
+-- Pending join challenges. New members stay restricted until they answer,
+-- and get kicked if they do not before expires_at.
+CREATE TABLE captcha (
+  user_id    INT PRIMARY KEY NOT NULL,
+  message_id INT         NOT NULL, -- the challenge message in the group
+  answer     INT         NOT NULL,
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Actions for the job worker to do later, like deleting a message. Done jobs
+-- get deleted, those that failed too often stay with failed set.
+CREATE TABLE job (
+  id         SERIAL PRIMARY KEY,
+  kind       TEXT        NOT NULL, -- delete_message, reminder, yell or whisper
+  payload    TEXT        NOT NULL DEFAULT '', -- json arguments of the job
+  run_at     TIMESTAMP WITH TIME ZONE NOT NULL,
+  attempts   INT         NOT NULL DEFAULT 0,
+  last_error TEXT        NOT NULL DEFAULT '',
+  failed     BOOL        NOT NULL DEFAULT FALSE, -- given up after too many attempts
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+CREATE INDEX job_run_at ON job (run_at);
+
+-- Append-only log of moderation actions. The actor is the bot itself for
+-- automatic actions, like deleting messages that are not bids.
+CREATE TABLE audit_log (
+  id         SERIAL PRIMARY KEY,
+  actor_id   INT         NOT NULL,
+  target_id  INT         NOT NULL,
+  action     TEXT        NOT NULL, -- delete, ban, unban, promote, demote, enlist, strike, pardon, mute
+  reason     TEXT        NOT NULL DEFAULT '',
+  message    TEXT        NOT NULL DEFAULT '', -- text of the deleted message
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+CREATE INDEX audit_log_target_id ON audit_log (target_id);
+
+-- Strikes for unpaid wins, retracted bids and moderation actions. Pardoned
+-- strikes are kept for the record but do not count towards the thresholds.
+CREATE TABLE strike (
+  id         SERIAL PRIMARY KEY,
+  user_id    INT         NOT NULL REFERENCES botuser(id),
+  kind       TEXT        NOT NULL, -- unpaid, retracted or moderation
+  reason     TEXT        NOT NULL DEFAULT '',
+  auction_id INT         NOT NULL DEFAULT 0, -- auction the strike is about, if any
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  pardoned   BOOL        NOT NULL DEFAULT FALSE
 );
 
 
@@ -16,6 +92,51 @@ create table auction (
   end_time TIMESTAMP WITH TIME zone, -- auction end time
   bid_val FLOAT,
   bid_type TEXT,
+  bidder_id INT NOT NULL DEFAULT 0, -- telegram id of the leading bidder, the winner once ended
   bid_msg_id INT default 0,
-  ended bool DEFAULT FALSE
-);
\ No newline at end of file
+  ended bool DEFAULT FALSE,
+  payment_status TEXT NOT NULL DEFAULT 'unpaid', -- settlement state: unpaid, pending or paid
+  payment_confirmations INT NOT NULL DEFAULT 0,
+  payment_txid TEXT NOT NULL DEFAULT '', -- the transaction that pays for the auction, once seen
+  reminder_plan TEXT NOT NULL DEFAULT '', -- json reminder plan, empty for the configured one
+  phase TEXT NOT NULL DEFAULT 'open', -- open, countdown, paused, ended or cancelled
+  countdown INT NOT NULL DEFAULT 0, -- the last number counted down, while in the countdown phase
+  countdown_msg_id INT NOT NULL DEFAULT 0, -- the pinned live countdown message, while there is one
+  previous_pin_msg_id INT NOT NULL DEFAULT 0, -- the message pinned before it, to pin again afterwards
+  remaining BIGINT, -- nanoseconds that were left when the auction got paused
+  cancel_reason TEXT NOT NULL DEFAULT '',
+  start_time TIMESTAMP WITH TIME ZONE, -- bids are taken from then on, null for right away
+  recurring_id INT NOT NULL DEFAULT 0, -- the recurring auction that created it, 0 if none
+  lot TEXT NOT NULL DEFAULT '', -- what is auctioned, if the recurring auction named it
+  UNIQUE (recurring_id, start_time)
+);
+-- a transaction pays for one auction only
+CREATE UNIQUE INDEX auction_payment_txid ON auction (payment_txid) WHERE payment_txid <> '';
+
+-- Auctions that repeat on a schedule. Their auction rows get created a while
+-- before they start.
+create table recurring_auction (
+  id SERIAL PRIMARY KEY,
+  schedule TEXT NOT NULL, -- cron expression, or "every" and an interval
+  duration BIGINT NOT NULL, -- nanoseconds from the start to the end of each auction
+  reminder_plan TEXT NOT NULL DEFAULT '', -- json reminder plan of the auctions, empty for the configured one
+  start_bid_val FLOAT NOT NULL DEFAULT 0, -- the auctions take bids above it
+  start_bid_type TEXT NOT NULL DEFAULT '', -- empty for no starting bid
+  lot TEXT NOT NULL DEFAULT '',
+  paused BOOL NOT NULL DEFAULT FALSE,
+  next_start TIMESTAMP WITH TIME ZONE NOT NULL, -- start of the next auction to create
+  created_by INT NOT NULL DEFAULT 0,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- Every accepted bid, the latest one of an auction is also kept in auction.
+create table bid (
+  id SERIAL PRIMARY KEY,
+  auction_id INT NOT NULL REFERENCES auction(id),
+  user_id INT NOT NULL REFERENCES botuser(id),
+  value FLOAT NOT NULL,
+  coin_type TEXT NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+create index bid_auction_id on bid (auction_id);
+create index bid_user_id on bid (user_id);
\ No newline at end of file
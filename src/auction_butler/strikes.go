@@ -0,0 +1,191 @@
+package auction_butler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of strikes a user can get
+const (
+	strikeUnpaid     = "unpaid"
+	strikeRetracted  = "retracted"
+	strikeModeration = "moderation"
+)
+
+var strikeKinds = []string{strikeUnpaid, strikeRetracted, strikeModeration}
+
+func isStrikeKind(kind string) bool {
+	for _, k := range strikeKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns why the user may not bid right now, for the audit log, and the
+// message that tells them, or empty strings if bidding is allowed.
+func (bot *Bot) biddingRestriction(u *User) (reason, text string) {
+	if !u.Can(permBid) {
+		return "bidding banned", bot.text(u, "bidding_banned", nil)
+	}
+	if until := u.SuspendedUntil.Time; u.SuspendedUntil.Valid && until.After(bot.clock.Now()) {
+		text = bot.text(u, "bidding_suspended", suspendedMessage{
+			Until:   bot.userTime(u, until),
+			Strikes: bot.db.GetActiveStrikeCount(u.ID),
+		})
+		return "bidding suspended until " + niceTime(until.UTC()), text
+	}
+	return "", ""
+}
+
+// Records a strike against the user and applies the suspension or ban the
+// configured thresholds call for. Returns what happened.
+func (bot *Bot) addStrike(u *User, kind, reason string, auctionID int) (*strikeMessage, error) {
+	if !u.Exists() {
+		if err := bot.db.PutUser(u); err != nil {
+			return nil, fmt.Errorf("failed to save the user: %v", err)
+		}
+	}
+	if err := bot.db.PutStrike(&Strike{
+		UserID:    u.ID,
+		Kind:      kind,
+		Reason:    reason,
+		AuctionID: auctionID,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to save strike: %v", err)
+	}
+
+	count := bot.db.GetActiveStrikeCount(u.ID)
+	result := &strikeMessage{Name: u.NameAndTags(), Count: count}
+
+	cfg := bot.config.Strikes
+	if cfg.BanAfter > 0 && count >= cfg.BanAfter && !u.Banned {
+		u.Banned = true
+		u.BannedByStrikes = true
+		result.Banned = true
+	} else if cfg.SuspendAfter > 0 && count >= cfg.SuspendAfter && !u.Banned {
+		u.SuspendedUntil = NewNullTime(bot.clock.Now().Add(cfg.SuspendFor.Duration))
+		result.SuspendedUntil = niceTime(u.SuspendedUntil.Time.UTC())
+	} else {
+		return result, nil
+	}
+
+	if err := bot.db.PutUser(u); err != nil {
+		return nil, fmt.Errorf("failed to change user status: %v", err)
+	}
+
+	log.Printf("strike: %s now has %d strikes, banned: %v, suspended until: %s", u.NameAndTags(), count, u.Banned, result.SuspendedUntil)
+	return result, nil
+}
+
+// Gives strikes to the winners of auctions that no payment was seen for.
+func (bot *Bot) checkUnpaidWins() {
+	if bot.config.Strikes.PaymentDeadline.Duration <= 0 {
+		return
+	}
+	auctions, err := bot.db.GetUnsettledAuctions()
+	if err != nil {
+		log.Printf("failed to get unsettled auctions: %v", err)
+		return
+	}
+	for i := range auctions {
+		if auctions[i].PaymentStatus == paymentUnpaid {
+			bot.strikeUnpaidWin(&auctions[i])
+		}
+	}
+}
+
+// Gives a strike to winners who did not pay within the configured deadline.
+func (bot *Bot) strikeUnpaidWin(auction *Auction) {
+	deadline := bot.config.Strikes.PaymentDeadline.Duration
+	if deadline <= 0 || bot.clock.Now().Sub(auction.EndTime.Time) < deadline {
+		return
+	}
+	if bot.db.HasAuctionStrike(auction.ID) {
+		return
+	}
+
+	winner := bot.db.GetUser(auction.BidderID)
+	if winner == nil {
+		return
+	}
+
+	reason := fmt.Sprintf("auction #%d not paid", auction.ID)
+	result, err := bot.addStrike(winner, strikeUnpaid, reason, auction.ID)
+	if err != nil {
+		log.Printf("failed to strike unpaid win of auction %d: %v", auction.ID, err)
+		return
+	}
+	bot.audit(nil, winner.ID, auditStrike, reason, "")
+	bot.notifyAdmins("unpaid_strike", unpaidStrikeMessage{auction.ID, *result})
+}
+
+func (bot *Bot) handleCommandStrike(ctx *Context, command, args string) error {
+	user, words, err := bot.targetUser(ctx, args)
+	if err != nil {
+		return err
+	}
+	if len(words) == 0 || !isStrikeKind(words[0]) {
+		return fmt.Errorf("strike kind should be one of: %s", strings.Join(strikeKinds, ", "))
+	}
+
+	result, err := bot.addStrike(user, words[0], strings.Join(words[1:], " "), 0)
+	if err != nil {
+		return err
+	}
+	bot.audit(ctx.User, user.ID, auditStrike, strings.Join(words, " "), "")
+	return bot.ReplyTemplate(ctx, "strike_added", result)
+}
+
+func (bot *Bot) handleCommandStrikes(ctx *Context, command, args string) error {
+	user, _, err := bot.targetUser(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	strikes, err := bot.db.GetStrikes(user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get strikes: %v", err)
+	}
+
+	msg := strikesMessage{Name: user.NameAndTags()}
+	if user.SuspendedUntil.Valid && user.SuspendedUntil.Time.After(bot.clock.Now()) {
+		msg.SuspendedUntil = niceTime(user.SuspendedUntil.Time.UTC())
+	}
+	for _, strike := range strikes {
+		msg.Strikes = append(msg.Strikes, strikeLine{
+			Time:     niceTime(strike.CreatedAt.UTC()),
+			Kind:     strike.Kind,
+			Reason:   strike.Reason,
+			Pardoned: strike.Pardoned,
+		})
+	}
+
+	return bot.replyTemplateLines(ctx, "strikes", msg)
+}
+
+func (bot *Bot) handleCommandPardon(ctx *Context, command, args string) error {
+	user, words, err := bot.targetUser(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	pardoned, err := bot.db.PardonStrikes(user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to pardon strikes: %v", err)
+	}
+
+	// bans by a moderator stay, they need /unban
+	if user.BannedByStrikes {
+		user.Banned = false
+		user.BannedByStrikes = false
+	}
+	user.SuspendedUntil = NullTime{}
+	if err := bot.db.PutUser(user); err != nil {
+		return fmt.Errorf("failed to change user status: %v", err)
+	}
+	bot.audit(ctx.User, user.ID, auditPardon, strings.Join(words, " "), "")
+
+	return bot.ReplyTemplate(ctx, "pardoned", pardonMessage{user.NameAndTags(), pardoned})
+}
@@ -0,0 +1,113 @@
+package auction_butler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/telegram-bot-api.v4"
+)
+
+// Winners who did not pay in time get one strike, also without a payment
+// watcher for the currency.
+func TestUnpaidWinStrikes(t *testing.T) {
+	config := testConfig()
+	config.Strikes = StrikesConfig{
+		SuspendAfter:    1,
+		SuspendFor:      NewDuration(24 * time.Hour),
+		PaymentDeadline: NewDuration(48 * time.Hour),
+	}
+	bot, clock, _ := newTestBot(t, config)
+
+	// overdue, not overdue yet, and overdue but paid partly
+	ends := map[int]time.Time{
+		1: testStart.Add(-49 * time.Hour),
+		2: testStart.Add(-time.Hour),
+		3: testStart.Add(-49 * time.Hour),
+	}
+	for id := 1; id <= 3; id++ {
+		if err := bot.db.PutUser(&User{ID: 40 + id, UserName: "winner"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := bot.db.PutAuction(ends[id]); err != nil {
+			t.Fatal(err)
+		}
+		bot.db.SetAuctionBid(id, &Bid{Value: 1, CoinType: "SKY"}, 40+id)
+		bot.db.EndAuction(id)
+	}
+	bot.db.SetAuctionPayment(3, paymentPending, 1, "txid")
+
+	bot.checkUnpaidWins()
+	bot.checkUnpaidWins()
+	for id, want := range map[int]int{41: 1, 42: 0, 43: 0} {
+		if got := bot.db.GetActiveStrikeCount(id); got != want {
+			t.Errorf("user %d has %d strikes, want %d", id, got, want)
+		}
+	}
+	winner := bot.db.GetUser(41)
+	if want := clock.Now().Add(24 * time.Hour); !winner.SuspendedUntil.Valid || !winner.SuspendedUntil.Time.Equal(want) {
+		t.Errorf("winner suspended until %v, want %v", winner.SuspendedUntil, want)
+	}
+}
+
+// A suspended bidder is told so in a message of the catalog, while the audit
+// log gets the reason as plain text.
+func TestBiddingRestriction(t *testing.T) {
+	bot, clock, _ := newTestBot(t, testConfig())
+	until := clock.Now().Add(time.Hour)
+	u := &User{ID: 42, UserName: "alice", SuspendedUntil: NewNullTime(until)}
+
+	reason, text := bot.biddingRestriction(u)
+	if want := "bidding suspended until " + niceTime(until); reason != want {
+		t.Errorf("reason is %q, want %q", reason, want)
+	}
+	if want := "Your bidding is suspended until"; !strings.HasPrefix(text, want) {
+		t.Errorf("text is %q, want it to start with %q", text, want)
+	}
+
+	clock.Advance(time.Hour)
+	if reason, text := bot.biddingRestriction(u); reason != "" || text != "" {
+		t.Errorf("still restricted after the suspension: %q, %q", reason, text)
+	}
+}
+
+// A pardon lifts a ban of the strikes, but not one of a moderator.
+func TestPardon(t *testing.T) {
+	config := testConfig()
+	config.Strikes = StrikesConfig{BanAfter: 1}
+	bot, _, _ := newTestBot(t, config)
+	users := []*User{
+		{ID: 1, UserName: "admin", Role: roleAdmin},
+		{ID: 2, UserName: "alice"},
+		{ID: 3, UserName: "bob", Banned: true},
+	}
+	for _, u := range users {
+		if err := bot.db.PutUser(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for id := 2; id <= 3; id++ {
+		if _, err := bot.addStrike(bot.db.GetUser(id), strikeModeration, "spam", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bot.db.GetUser(2).Banned {
+		t.Fatal("the strike did not ban alice")
+	}
+
+	ctx := &Context{
+		User:    bot.db.GetUser(1),
+		message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 1, Type: "private"}},
+	}
+	for _, name := range []string{"@alice", "@bob"} {
+		if err := bot.handleCommandPardon(ctx, "pardon", name); err != nil {
+			t.Fatalf("failed to pardon %s: %v", name, err)
+		}
+	}
+	if bot.db.GetUser(2).Banned {
+		t.Error("alice is still banned after the pardon")
+	}
+	if !bot.db.GetUser(3).Banned {
+		t.Error("the pardon lifted the ban of a moderator")
+	}
+}
@@ -0,0 +1,127 @@
+package auction_butler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Common time zone abbreviations. They stand for fixed offsets, so CET is
+// winter time even in summer.
+var zoneAbbreviations = map[string]int{
+	"UTC":  0,
+	"GMT":  0,
+	"WET":  0,
+	"BST":  1 * 3600,
+	"WEST": 1 * 3600,
+	"CET":  1 * 3600,
+	"CEST": 2 * 3600,
+	"EET":  2 * 3600,
+	"EEST": 3 * 3600,
+	"MSK":  3 * 3600,
+	"IST":  5*3600 + 1800,
+	"SGT":  8 * 3600,
+	"HKT":  8 * 3600,
+	"CST":  -6 * 3600,
+	"CDT":  -5 * 3600,
+	"EST":  -5 * 3600,
+	"EDT":  -4 * 3600,
+	"MST":  -7 * 3600,
+	"MDT":  -6 * 3600,
+	"PST":  -8 * 3600,
+	"PDT":  -7 * 3600,
+	"JST":  9 * 3600,
+	"KST":  9 * 3600,
+	"AEST": 10 * 3600,
+	"AEDT": 11 * 3600,
+}
+
+// Returns the zone of an IANA name like Europe/Berlin or of an abbreviation
+// like CET.
+func loadZone(name string) (*time.Location, error) {
+	if offset, found := zoneAbbreviations[strings.ToUpper(name)]; found {
+		return time.FixedZone(strings.ToUpper(name), offset), nil
+	}
+	// the zone of the server is no help to anybody else
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("unknown time zone: %s", name)
+	}
+	zone, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone: %s", name)
+	}
+	return zone, nil
+}
+
+// Returns the zone the user wants to see times in, the group's if they have
+// not set one or for a nil user.
+func (bot *Bot) zone(u *User) *time.Location {
+	if u != nil && u.Timezone != "" {
+		if zone, err := loadZone(u.Timezone); err == nil {
+			return zone
+		}
+	}
+	if bot.groupZone != nil {
+		return bot.groupZone
+	}
+	return time.UTC
+}
+
+// Formats the time in the zone of the user.
+func (bot *Bot) localTime(u *User, t time.Time) string {
+	return niceTime(t.In(bot.zone(u)))
+}
+
+// Formats the time for group announcements.
+func (bot *Bot) groupTime(t time.Time) string {
+	return bot.localTime(nil, t)
+}
+
+// Formats the time in the zone of the user, followed by UTC and how long
+// until then.
+func (bot *Bot) userTime(u *User, t time.Time) string {
+	return bot.bothTimes(t.In(bot.zone(u)))
+}
+
+// Formats the time in its own zone, followed by UTC if that differs and how
+// long until then if it is ahead.
+func (bot *Bot) bothTimes(t time.Time) string {
+	s := niceTime(t)
+	if utc := niceTime(t.UTC()); utc != s {
+		s += " (" + utc + ")"
+	}
+	if left := t.Sub(bot.clock.Now()); left > 0 {
+		s += ", in " + niceDuration(left)
+	}
+	return s
+}
+
+func (bot *Bot) handleCommandTimezone(ctx *Context, command, args string) error {
+	name := strings.TrimSpace(args)
+	if name == "" {
+		return bot.ReplyTemplate(ctx, "timezone", timezoneMessage{
+			Zone: bot.zone(ctx.User).String(),
+			Time: bot.localTime(ctx.User, bot.clock.Now()),
+		})
+	}
+
+	if strings.ToLower(name) == "default" {
+		name = ""
+	} else {
+		zone, err := loadZone(name)
+		if err != nil {
+			return fmt.Errorf("%v, give a name like Europe/Berlin or an abbreviation like CET", err)
+		}
+		name = zone.String()
+	}
+
+	ctx.User.Timezone = name
+	if err := bot.db.PutUser(ctx.User); err != nil {
+		return fmt.Errorf("failed to save time zone: %v", err)
+	}
+
+	return bot.ReplyTemplate(ctx, "timezone", timezoneMessage{
+		Zone: bot.zone(ctx.User).String(),
+		Time: bot.localTime(ctx.User, bot.clock.Now()),
+	})
+}
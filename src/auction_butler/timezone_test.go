@@ -0,0 +1,38 @@
+package auction_butler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNiceTime(t *testing.T) {
+	if got := niceTime(time.Date(2018, 3, 24, 18, 5, 0, 0, time.UTC)); got != "18:05 UTC 24.03" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestUserTime(t *testing.T) {
+	berlin, err := loadZone("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot := &Bot{config: &Config{}, clock: newFakeClock(testStart), groupZone: berlin}
+	end := testStart.Add(3*time.Hour + 12*time.Minute)
+
+	if got, want := bot.userTime(nil, end), "17:12 CEST 01.05 (15:12 UTC 01.05), in 3h12m"; got != want {
+		t.Errorf("group zone: got %q, want %q", got, want)
+	}
+	u := &User{Timezone: "UTC"}
+	if got, want := bot.userTime(u, end), "15:12 UTC 01.05, in 3h12m"; got != want {
+		t.Errorf("user zone: got %q, want %q", got, want)
+	}
+	if got, want := bot.userTime(u, testStart.Add(-time.Hour)), "11:00 UTC 01.05"; got != want {
+		t.Errorf("past time: got %q, want %q", got, want)
+	}
+
+	for _, name := range []string{"", "Local", "Mars/Olympus"} {
+		if _, err := loadZone(name); err == nil {
+			t.Errorf("expected an error for %q", name)
+		}
+	}
+}
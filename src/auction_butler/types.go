@@ -27,7 +27,22 @@ type User struct {
 	LastName  string `db:"last_name" json:"last_name,omitempty"`
 	Enlisted  bool   `json:"enlisted"`
 	Banned    bool   `json:"banned"`
-	Admin     bool   `json:"admin"`
+	// the strikes banned the user rather than a moderator, a pardon lifts it
+	BannedByStrikes bool `db:"banned_by_strikes" json:"banned_by_strikes"`
+	// what the user may do, see rolePermissions
+	Role string `db:"role" json:"role"`
+	// the admin sync gave the role, so it may take it back too
+	RoleSynced bool `db:"role_synced" json:"role_synced"`
+	// the role was given with /role, /promote or /demote, the sync keeps it
+	RoleManual bool `db:"role_manual" json:"role_manual"`
+	// bidding is not allowed until this time
+	SuspendedUntil NullTime `db:"suspended_until" json:"suspended_until"`
+	// language code of the message catalog to talk to the user in
+	Language string `db:"language" json:"language,omitempty"`
+	// time zone to show times in, an IANA name or an abbreviation
+	Timezone string `db:"timezone" json:"timezone,omitempty"`
+	// when the user first joined the group, if the bot saw it
+	JoinedAt NullTime `db:"joined_at" json:"joined_at"`
 
 	exists bool
 }
@@ -37,16 +52,11 @@ func (u *User) NameAndTags() string {
 	if u.Banned {
 		tags = append(tags, "banned")
 	}
-	if u.Admin {
-		tags = append(tags, "admin")
-	}
-
-	// If username is hidden use userid
-	identifier := u.UserName
-	if identifier == "" {
-		identifier = u.FirstName + " " + u.LastName
+	if u.Role != "" && u.Role != roleBidder {
+		tags = append(tags, u.Role)
 	}
 
+	identifier := u.Name()
 	if len(tags) > 0 {
 		return fmt.Sprintf("%s (%s)", identifier, strings.Join(tags, ", "))
 	}
@@ -54,10 +64,117 @@ func (u *User) NameAndTags() string {
 	return identifier
 }
 
+// Returns the username, or the full name if the username is hidden.
+func (u *User) Name() string {
+	if u.UserName == "" {
+		return strings.TrimSpace(u.FirstName + " " + u.LastName)
+	}
+	return u.UserName
+}
+
 func (u *User) Exists() bool {
 	return u.exists
 }
 
+// A bid that was accepted during an auction
+type PlacedBid struct {
+	ID        int       `db:"id" json:"id"`
+	AuctionID int       `db:"auction_id" json:"auction_id"`
+	UserID    int       `db:"user_id" json:"user_id"`
+	Value     float64   `db:"value" json:"value"`
+	CoinType  string    `db:"coin_type" json:"coin_type"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// An auction with statistics about its bids
+type AuctionResult struct {
+	Auction
+	BidCount    int `db:"bid_count" json:"bid_count"`
+	BidderCount int `db:"bidder_count" json:"bidder_count"`
+}
+
+// Lifetime bidding statistics of a user
+type UserBidStats struct {
+	Bids     int      `db:"bids" json:"bids"`
+	Auctions int      `db:"auctions" json:"auctions"`
+	FirstBid NullTime `db:"first_bid" json:"first_bid"`
+}
+
+type LeaderboardEntry struct {
+	UserID int     `db:"user_id" json:"user_id"`
+	Score  float64 `db:"score" json:"score"`
+}
+
+// Statistics over the ended auctions that had bids, prices are in SKY
+type AuctionStats struct {
+	Auctions      int     `db:"auctions" json:"auctions"`
+	AvgBids       float64 `db:"avg_bids" json:"avg_bids"`
+	AvgBidders    float64 `db:"avg_bidders" json:"avg_bidders"`
+	AvgPriceRatio float64 `db:"avg_price_ratio" json:"avg_price_ratio"`
+	TotalSold     float64 `db:"total_sold" json:"total_sold"`
+}
+
+// A challenge a new member has to solve before they may post
+type Captcha struct {
+	UserID    int       `db:"user_id" json:"user_id"`
+	MessageID int       `db:"message_id" json:"message_id"`
+	Answer    int       `db:"answer" json:"answer"`
+	ExpiresAt time.Time `db:"expires_at" json:"expires_at"`
+}
+
+// Something the job worker does at RunAt, see the job kinds
+type Job struct {
+	ID   int    `db:"id" json:"id"`
+	Kind string `db:"kind" json:"kind"`
+	// arguments as json, depending on the kind
+	Payload   string    `db:"payload" json:"payload"`
+	RunAt     time.Time `db:"run_at" json:"run_at"`
+	Attempts  int       `db:"attempts" json:"attempts"`
+	LastError string    `db:"last_error" json:"last_error,omitempty"`
+	Failed    bool      `db:"failed" json:"failed"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// A user joining or leaving the group
+type MembershipEvent struct {
+	ID     int    `db:"id" json:"id"`
+	UserID int    `db:"user_id" json:"user_id"`
+	Event  string `db:"event" json:"event"`
+	// who added or removed the user, zero if they did it themselves
+	ActorID   int       `db:"actor_id" json:"actor_id"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// A username the user had before renaming themselves
+type UsernameChange struct {
+	ID        int       `db:"id" json:"id"`
+	UserID    int       `db:"user_id" json:"user_id"`
+	UserName  string    `db:"username" json:"username"`
+	ChangedAt time.Time `db:"changed_at" json:"changed_at"`
+}
+
+// A moderation action, the audit log is never updated or deleted from
+type AuditEntry struct {
+	ID       int    `db:"id" json:"id"`
+	ActorID  int    `db:"actor_id" json:"actor_id"`
+	TargetID int    `db:"target_id" json:"target_id"`
+	Action   string `db:"action" json:"action"`
+	Reason   string `db:"reason" json:"reason"`
+	// text of the deleted message
+	Message   string    `db:"message" json:"message"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+type Strike struct {
+	ID        int       `db:"id" json:"id"`
+	UserID    int       `db:"user_id" json:"user_id"`
+	Kind      string    `db:"kind" json:"kind"`
+	Reason    string    `db:"reason" json:"reason"`
+	AuctionID int       `db:"auction_id" json:"auction_id"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	Pardoned  bool      `db:"pardoned" json:"pardoned"`
+}
+
 type Chat struct {
 	ID    int64  `json:"id"`
 	Title string `json:"title"`
@@ -65,12 +182,49 @@ type Chat struct {
 }
 
 type Auction struct {
-	ID        int      `db:"id" json:"id"`
-	EndTime   NullTime `db:"end_time" json:"end_time"`
-	BidVal    float64  `db:"bid_val"  json:"bid_val"`
-	BidType   string   `db:"bid_type" json:"bid_type"`
-	MessageID int      `db:"bid_msg_id" json:"bid_msg_id"`
-	Ended     bool     `db:"ended" json:"ended"`
+	ID                   int      `db:"id" json:"id"`
+	EndTime              NullTime `db:"end_time" json:"end_time"`
+	BidVal               float64  `db:"bid_val"  json:"bid_val"`
+	BidType              string   `db:"bid_type" json:"bid_type"`
+	BidderID             int      `db:"bidder_id" json:"bidder_id"`
+	MessageID            int      `db:"bid_msg_id" json:"bid_msg_id"`
+	Ended                bool     `db:"ended" json:"ended"`
+	PaymentStatus        string   `db:"payment_status" json:"payment_status"`
+	PaymentConfirmations int      `db:"payment_confirmations" json:"payment_confirmations"`
+	PaymentTxID          string   `db:"payment_txid" json:"payment_txid,omitempty"`
+	// reminder plan as json, the configured one is used if empty
+	ReminderPlan string `db:"reminder_plan" json:"reminder_plan"`
+	// how far the engine got, see the auction phases
+	Phase     string `db:"phase" json:"phase"`
+	Countdown int64  `db:"countdown" json:"countdown"`
+	// the live countdown message and the message pinned before it
+	CountdownMessageID int `db:"countdown_msg_id" json:"countdown_msg_id"`
+	PreviousPinID      int `db:"previous_pin_msg_id" json:"previous_pin_msg_id"`
+	// time that was left when the auction got paused
+	Remaining    Duration `db:"remaining" json:"remaining"`
+	CancelReason string   `db:"cancel_reason" json:"cancel_reason,omitempty"`
+	// auctions created ahead of time take no bids before they start
+	StartTime   NullTime `db:"start_time" json:"start_time"`
+	RecurringID int      `db:"recurring_id" json:"recurring_id,omitempty"`
+	// what is auctioned, set by recurring auctions
+	Lot string `db:"lot" json:"lot,omitempty"`
+}
+
+// An auction that repeats on a schedule
+type RecurringAuction struct {
+	ID       int      `db:"id" json:"id"`
+	Schedule string   `db:"schedule" json:"schedule"`
+	Duration Duration `db:"duration" json:"duration"`
+	// reminder plan of the auctions as json, the configured one is used if empty
+	ReminderPlan string `db:"reminder_plan" json:"reminder_plan"`
+	// the auctions take bids above it, none if the type is empty
+	StartBidVal  float64   `db:"start_bid_val" json:"start_bid_val"`
+	StartBidType string    `db:"start_bid_type" json:"start_bid_type"`
+	Lot          string    `db:"lot" json:"lot"`
+	Paused       bool      `db:"paused" json:"paused"`
+	NextStart    time.Time `db:"next_start" json:"next_start"`
+	CreatedBy    int       `db:"created_by" json:"created_by"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
 }
 
 func (d Duration) Value() (driver.Value, error) {
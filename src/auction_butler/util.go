@@ -117,9 +117,9 @@ func parseBid(bid string, foundBidType string) *Bid {
 	}
 }
 
-func niceTime(time time.Time) string {
+func niceTime(t time.Time) string {
 	//18:00 UTC 24.03
-	return fmt.Sprintf("%v:%v %v %v.%v", time.Hour(), time.Minute(), time.Location().String(), time.Day(), time.Month())
+	return t.Format("15:04 MST 02.01")
 
 }
 